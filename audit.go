@@ -0,0 +1,132 @@
+package zapx
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// auditLogID is the log id of the audit entries, see LogID.
+const auditLogID = "audit"
+
+// Audit logs that action, e.g. "storage.objects.delete", was performed on
+// resource, e.g. "projects/_/buckets/b/objects/o", with the outcome err, nil
+// on success. The entry is written by the logger of ctx, see
+// LoggerFromContext, named "audit", to the "audit" log, see LogID and
+// WithAuditSink, at info level, or warn level if err is not nil. Its "audit"
+// field is shaped after the AuditLog of Cloud Audit Logs: the principal is the
+// user of the entry, see ContextWithUser, and the request metadata the caller
+// of the request of ctx, see HTTPMiddleware and UnaryServerInterceptor.
+func Audit(ctx context.Context, action, resource string, err error, fields ...zapcore.Field) {
+	level := zapcore.InfoLevel
+	if err != nil {
+		level = zapcore.WarnLevel
+	}
+	// skip Audit
+	ce := LoggerFromContext(ctx).Named("audit").WithOptions(zap.AddCallerSkip(1)).Check(level, action+" "+resource)
+	if ce == nil {
+		return
+	}
+	md := requestMetadataFrom(ctx)
+	a := auditLog{
+		methodName:   action,
+		resourceName: resource,
+		principal:    UserFromContext(ctx),
+		callerIP:     md.callerIP,
+		userAgent:    md.userAgent,
+		err:          err,
+	}
+	fs := make([]zapcore.Field, 0, len(fields)+3)
+	fs = append(fs, Context(ctx), LogID(auditLogID), zap.Object(logKeyAudit, a))
+	ce.Write(append(fs, fields...)...)
+}
+
+// auditLog is the payload of an audit entry.
+type auditLog struct {
+	methodName   string
+	resourceName string
+	principal    string
+	callerIP     string
+	userAgent    string
+	err          error
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (a auditLog) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("@type", "type.googleapis.com/google.cloud.audit.AuditLog")
+	addNonEmpty(e, "methodName", a.methodName)
+	addNonEmpty(e, "resourceName", a.resourceName)
+	if a.principal != "" {
+		e.AddObject("authenticationInfo", stringMap{"principalEmail": a.principal})
+	}
+	if a.callerIP != "" || a.userAgent != "" {
+		md := make(stringMap, 2)
+		if a.callerIP != "" {
+			md["callerIp"] = a.callerIP
+		}
+		if a.userAgent != "" {
+			md["callerSuppliedUserAgent"] = a.userAgent
+		}
+		e.AddObject("requestMetadata", md)
+	}
+	return e.AddObject("status", auditStatus{a.err})
+}
+
+// auditStatus is the google.rpc.Status of an error.
+type auditStatus struct {
+	err error
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (s auditStatus) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	st := status.Convert(s.err)
+	e.AddInt("code", int(st.Code()))
+	addNonEmpty(e, "message", st.Message())
+	return nil
+}
+
+type requestMetadataContextKey struct{}
+
+// requestMetadata is the caller of a request.
+type requestMetadata struct {
+	callerIP  string
+	userAgent string
+}
+
+// contextWithRequestMetadata returns a copy of ctx carrying the caller of r,
+// for Audit.
+func contextWithRequestMetadata(ctx context.Context, e HTTPRequestEntry) context.Context {
+	return context.WithValue(ctx, requestMetadataContextKey{}, requestMetadata{callerIP: e.remoteIP(), userAgent: e.userAgent()})
+}
+
+// requestMetadataFrom returns the caller of the request of ctx, either set by
+// HTTPMiddleware or found in the peer and the incoming metadata of a grpc
+// call.
+func requestMetadataFrom(ctx context.Context) requestMetadata {
+	if md, ok := ctx.Value(requestMetadataContextKey{}).(requestMetadata); ok {
+		return md
+	}
+	var rm requestMetadata
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		rm.callerIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(rm.callerIP); err == nil {
+			rm.callerIP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if fwd := md.Get("x-forwarded-for"); len(fwd) != 0 {
+			ip, _, _ := strings.Cut(fwd[0], ",")
+			rm.callerIP = strings.TrimSpace(ip)
+		}
+		if ua := md.Get("user-agent"); len(ua) != 0 {
+			rm.userAgent = ua[0]
+		}
+	}
+	return rm
+}
@@ -0,0 +1,126 @@
+package zapx
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// breakers holds a breaker per notifier, so that a failing backend, e.g.
+// a Slack outage, does not pause the notifications of the others.
+type breakers struct {
+	max      int
+	cooldown time.Duration
+	onError  errorHandler
+
+	mu  sync.Mutex
+	set map[interface{}]*breaker
+}
+
+func newBreakers(max int, cooldown time.Duration, onError errorHandler) *breakers {
+	return &breakers{max: max, cooldown: cooldown, onError: onError, set: make(map[interface{}]*breaker)}
+}
+
+// of returns the breaker of n, nil if there are no breakers.
+func (bs *breakers) of(n Notifier) *breaker {
+	if bs == nil {
+		return nil
+	}
+	key := breakerKey(n)
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.set[key]
+	if !ok {
+		b = newBreaker(notifierName(n), bs.max, bs.cooldown, bs.onError)
+		bs.set[key] = b
+	}
+	return b
+}
+
+// slackBreakerKey is the breaker key of the slack notifiers, created for
+// every notification, by destination.
+type slackBreakerKey struct {
+	dest string
+}
+
+// breakerKey returns the key identifying n among the breakers.
+func breakerKey(n Notifier) interface{} {
+	switch n := n.(type) {
+	case *slackNotifier:
+		if n.url != "" {
+			return slackBreakerKey{n.url}
+		}
+		return slackBreakerKey{n.channel}
+	case slackDigestTarget:
+		return slackBreakerKey{n.url}
+	}
+	switch v := reflect.ValueOf(n); {
+	case v.Type().Comparable():
+		return n
+	case v.Kind() == reflect.Func || v.Kind() == reflect.Map || v.Kind() == reflect.Slice:
+		// e.g. a NotifierFunc, by function.
+		return v.Pointer()
+	default:
+		return notifierName(n)
+	}
+}
+
+// breaker stops the delivery of notifications after consecutive failures,
+// e.g. during a Slack outage, so that the retries do not pile up. Once the
+// cool-down has passed, a single notification probes the delivery: the
+// breaker closes if it succeeds, and opens again otherwise.
+type breaker struct {
+	name     string
+	max      int
+	cooldown time.Duration
+	onError  errorHandler
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newBreaker(name string, max int, cooldown time.Duration, onError errorHandler) *breaker {
+	return &breaker{name: name, max: max, cooldown: cooldown, onError: onError}
+}
+
+// allow reports whether a notification may be attempted at now.
+func (b *breaker) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if b.probing || now.Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// report records the outcome of an attempted notification.
+func (b *breaker) report(err error, now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		b.probing = false
+		return
+	}
+	b.failures++
+	if b.probing || b.failures >= b.max {
+		if b.openUntil.IsZero() {
+			b.onError.errorf("zapx: %d consecutive %s notification failures, pausing its notifications for %s: %w", b.failures, b.name, b.cooldown, err)
+		}
+		b.openUntil = now.Add(b.cooldown)
+		b.probing = false
+	}
+}
@@ -0,0 +1,96 @@
+package zapx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lixin9311/backoff/v2"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBreaker(t *testing.T) {
+	var slackUp int32
+	var slackCalls, otherCalls int64
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&slackCalls, 1)
+		if atomic.LoadInt32(&slackUp) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer slack.Close()
+	other := NotifierFunc(func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+		atomic.AddInt64(&otherCalls, 1)
+		return nil
+	})
+	clock := newFakeClock()
+	logger := Zap(zapcore.DebugLevel,
+		WithOutput(zapcore.AddSync(ioutil.Discard)),
+		WithClock(clock),
+		WithSlackURL(slack.URL),
+		WithNotifier(other),
+		WithSlackRetry(0, backoff.Backoff{}),
+		WithCircuitBreaker(2, time.Minute),
+		WithInternalErrorHandler(func(error) {}),
+	)
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		up      bool
+		// wantSlack and wantOther are the calls of the notifiers for the
+		// notification of the step.
+		wantSlack, wantOther int64
+	}{
+		{"first failure", 0, false, 1, 1},
+		{"second failure opens the slack breaker", 0, false, 1, 1},
+		{"slack paused, other notified", 0, false, 0, 1},
+		{"still paused before the cool-down", time.Minute - time.Second, false, 0, 1},
+		{"failed probe opens again", time.Second, false, 1, 1},
+		{"paused again", 0, true, 0, 1},
+		{"probe succeeds", time.Minute, true, 1, 1},
+		{"closed", 0, true, 1, 1},
+	}
+	for _, step := range steps {
+		clock.Add(step.advance)
+		if step.up {
+			atomic.StoreInt32(&slackUp, 1)
+		}
+		slackBefore, otherBefore := atomic.LoadInt64(&slackCalls), atomic.LoadInt64(&otherCalls)
+		logger.Error("boom", Slack())
+		if err := logger.Sync(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := atomic.LoadInt64(&slackCalls) - slackBefore; got != step.wantSlack {
+			t.Errorf("%s: slack called %d times, want %d", step.name, got, step.wantSlack)
+		}
+		if got := atomic.LoadInt64(&otherCalls) - otherBefore; got != step.wantOther {
+			t.Errorf("%s: other notifier called %d times, want %d", step.name, got, step.wantOther)
+		}
+	}
+}
+
+func TestBreakerKey(t *testing.T) {
+	f := NotifierFunc(func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error { return nil })
+	g := NotifierFunc(func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error { return errCircuitOpen })
+	tests := []struct {
+		name string
+		a, b Notifier
+		same bool
+	}{
+		{"same webhook", &slackNotifier{url: "https://a"}, &slackNotifier{url: "https://a"}, true},
+		{"other webhook", &slackNotifier{url: "https://a"}, &slackNotifier{url: "https://b"}, false},
+		{"same channel", &slackNotifier{channel: "#a"}, &slackNotifier{channel: "#a"}, true},
+		{"same func", f, f, true},
+		{"other func", f, g, false},
+	}
+	for _, tt := range tests {
+		if got := breakerKey(tt.a) == breakerKey(tt.b); got != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, got, tt.same)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EntryFields assembles the standard zapx fields of an entry. Invalid values
+// are left out of Fields and reported by Err.
+type EntryFields struct {
+	context  *zapcore.Field
+	metadata *zapcore.Field
+	user     string
+	request  *HTTPRequestEntry
+	slack    *zapcore.Field
+	labels   []zapcore.Field
+	fields   []zapcore.Field
+	err      error
+}
+
+// NewEntryFields returns an empty EntryFields.
+func NewEntryFields() *EntryFields {
+	return &EntryFields{}
+}
+
+// Trace sets the trace and grpc information carried by ctx, see Context.
+func (b *EntryFields) Trace(ctx context.Context) *EntryFields {
+	if ctx == nil {
+		b.err = multierr.Append(b.err, errors.New("zapx: nil context"))
+		return b
+	}
+	f := Context(ctx)
+	b.context = &f
+	return b
+}
+
+// Metadata sets the incoming grpc metadata carried by ctx, see Metadata.
+func (b *EntryFields) Metadata(ctx context.Context) *EntryFields {
+	if ctx == nil {
+		b.err = multierr.Append(b.err, errors.New("zapx: nil context"))
+		return b
+	}
+	f := Metadata(ctx)
+	b.metadata = &f
+	return b
+}
+
+// User sets the user reported to Error Reporting.
+func (b *EntryFields) User(id string) *EntryFields {
+	if id == "" {
+		b.err = multierr.Append(b.err, errors.New("zapx: empty user"))
+		return b
+	}
+	b.user = id
+	return b
+}
+
+// Label adds a label, replacing any previous label with the same key.
+func (b *EntryFields) Label(key, val string) *EntryFields {
+	if key == "" {
+		b.err = multierr.Append(b.err, fmt.Errorf("zapx: empty label key for value %q", val))
+		return b
+	}
+	f := Label(key, val)
+	for i := range b.labels {
+		if b.labels[i].Key == f.Key {
+			b.labels[i] = f
+			return b
+		}
+	}
+	b.labels = append(b.labels, f)
+	return b
+}
+
+// Request sets the http request, see Request.
+func (b *EntryFields) Request(req HTTPRequestEntry) *EntryFields {
+	b.request = &req
+	return b
+}
+
+// Slack enables the slack notification, see Slack.
+func (b *EntryFields) Slack(url ...string) *EntryFields {
+	f := Slack(url...)
+	b.slack = &f
+	return b
+}
+
+// Field adds arbitrary fields.
+func (b *EntryFields) Field(fields ...zapcore.Field) *EntryFields {
+	b.fields = append(b.fields, fields...)
+	return b
+}
+
+// Err returns the validation errors, if any.
+func (b *EntryFields) Err() error {
+	return b.err
+}
+
+// Fields returns the assembled fields.
+func (b *EntryFields) Fields() []zapcore.Field {
+	fs := make([]zapcore.Field, 0, len(b.labels)+len(b.fields)+5)
+	for _, f := range []*zapcore.Field{b.context, b.metadata, b.slack} {
+		if f != nil {
+			fs = append(fs, *f)
+		}
+	}
+	if b.user != "" {
+		fs = append(fs, zap.String("user", b.user))
+	}
+	if b.request != nil {
+		fs = append(fs, Request(*b.request))
+	}
+	fs = append(fs, b.labels...)
+	return append(fs, b.fields...)
+}
@@ -0,0 +1,55 @@
+package zapx
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// callerPath renders the file path of the caller in the sourceLocation and
+// reportLocation of the entries.
+type callerPath func(caller zapcore.EntryCaller) string
+
+// trimmedCallerPath keeps the package and file name, as zap does.
+func trimmedCallerPath(caller zapcore.EntryCaller) string {
+	return caller.TrimmedPath()
+}
+
+// fullCallerPath keeps the full file path.
+func fullCallerPath(caller zapcore.EntryCaller) string {
+	return caller.File
+}
+
+// prefixCallerPath trims the prefix off the file path of the caller.
+func prefixCallerPath(prefix string) callerPath {
+	return func(caller zapcore.EntryCaller) string {
+		if i := strings.Index(caller.File, prefix); i != -1 {
+			return caller.File[i+len(prefix):]
+		}
+		return caller.File
+	}
+}
+
+// moduleCallerPath returns the file path of the caller relative to the root
+// of the main module. With -trimpath builds the path starts with the module
+// path, otherwise the working directory is assumed to be the module root.
+func moduleCallerPath() callerPath {
+	var prefixes []string
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Path != "" {
+		prefixes = append(prefixes, info.Main.Path+"/")
+	}
+	if wd, err := os.Getwd(); err == nil {
+		prefixes = append(prefixes, filepath.ToSlash(wd)+"/")
+	}
+	return func(caller zapcore.EntryCaller) string {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(caller.File, prefix) {
+				return caller.File[len(prefix):]
+			}
+		}
+		return caller.File
+	}
+}
@@ -0,0 +1,153 @@
+package zapx
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const logKeyCanonicalLine = "zapx.canonical"
+
+type canonicalLineKey struct{}
+
+// CanonicalLine accumulates the key facts of a request, e.g. database calls,
+// cache hits and errors, and writes them as one summary entry when the
+// request ends. All methods are safe for concurrent use and no-ops on a nil
+// CanonicalLine.
+type CanonicalLine struct {
+	replace bool
+
+	mu       sync.Mutex
+	emitted  bool
+	level    zapcore.Level
+	fields   []zapcore.Field
+	counters map[string]int64
+	lines    []canonicalEntry
+}
+
+type canonicalEntry struct {
+	level   zapcore.Level
+	message string
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (e canonicalEntry) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("severity", e.level.CapitalString())
+	enc.AddString("message", e.message)
+	return nil
+}
+
+type canonicalEntries []canonicalEntry
+
+// MarshalLogArray is ArrayMarshaler implementation.
+func (es canonicalEntries) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, e := range es {
+		if err := enc.AppendObject(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewCanonicalLine starts a canonical log line and returns a copy of ctx
+// carrying it. When replace is true, the entries below warn level written by
+// the loggers carrying the line's Field are not written, but only listed in
+// the summary entry.
+func NewCanonicalLine(ctx context.Context, replace bool) (context.Context, *CanonicalLine) {
+	line := &CanonicalLine{replace: replace, level: zapcore.InfoLevel, counters: make(map[string]int64)}
+	return context.WithValue(ctx, canonicalLineKey{}, line), line
+}
+
+// CanonicalLineFromContext returns the canonical log line carried by ctx, or
+// nil.
+func CanonicalLineFromContext(ctx context.Context) *CanonicalLine {
+	line, _ := ctx.Value(canonicalLineKey{}).(*CanonicalLine)
+	return line
+}
+
+// Add adds fields to the summary entry.
+func (l *CanonicalLine) Add(fields ...zapcore.Field) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fields = append(l.fields, fields...)
+}
+
+// Count adds n to the counter key of the summary entry.
+func (l *CanonicalLine) Count(key string, n int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counters[key] += n
+}
+
+// Field constructs a field that makes the core list the entries of the
+// logger in the summary entry, see NewCanonicalLine.
+func (l *CanonicalLine) Field() zapcore.Field {
+	if l == nil {
+		return zap.Skip()
+	}
+	return zap.Reflect(logKeyCanonicalLine, l)
+}
+
+// observe records ent, and reports whether it should still be written.
+func (l *CanonicalLine) observe(ent zapcore.Entry) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.emitted {
+		return true
+	}
+	if ent.Level > l.level {
+		l.level = ent.Level
+	}
+	l.lines = append(l.lines, canonicalEntry{level: ent.Level, message: ent.Message})
+	return !l.replace || ent.Level >= zapcore.WarnLevel
+}
+
+// Emit writes the summary entry, at the highest level observed and at least
+// at info level.
+func (l *CanonicalLine) Emit(logger *zap.Logger, msg string, fields ...zapcore.Field) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	fs := make([]zapcore.Field, 0, len(l.fields)+len(l.counters)+len(fields)+1)
+	fs = append(fs, l.fields...)
+	keys := make([]string, 0, len(l.counters))
+	for key := range l.counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fs = append(fs, zap.Int64(key, l.counters[key]))
+	}
+	if len(l.lines) != 0 {
+		fs = append(fs, zap.Array("lines", canonicalEntries(l.lines)))
+	}
+	level := l.level
+	l.emitted = true
+	l.mu.Unlock()
+	fs = append(fs, fields...)
+	if ce := logger.WithOptions(zap.AddCallerSkip(1)).Check(level, msg); ce != nil {
+		ce.Write(fs...)
+	}
+}
+
+func findCanonicalLine(fields []zapcore.Field) *CanonicalLine {
+	for _, f := range fields {
+		if f.Key != logKeyCanonicalLine {
+			continue
+		}
+		if line, ok := f.Interface.(*CanonicalLine); ok {
+			return line
+		}
+	}
+	return nil
+}
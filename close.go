@@ -0,0 +1,90 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// resources are the sinks and the clients opened by Zap, shared by a logger
+// and all the loggers derived from it, and closed by Close.
+type resources struct {
+	once    sync.Once
+	closers []io.Closer
+	err     error
+}
+
+func (r *resources) add(c io.Closer) {
+	r.closers = append(r.closers, c)
+}
+
+// closerFunc adapts a function to an io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// sampledCore is the sampler of WithSampling, keeping the core it samples
+// for Close.
+type sampledCore struct {
+	zapcore.Core
+	s *stackdriver
+}
+
+func (c sampledCore) With(fields []zapcore.Field) zapcore.Core {
+	return sampledCore{Core: c.Core.With(fields), s: c.s}
+}
+
+// coreOf returns the core of logger, if created by Zap.
+func coreOf(logger *zap.Logger) (*stackdriver, bool) {
+	core := logger.Core()
+	for {
+		switch c := core.(type) {
+		case *stackdriver:
+			return c, true
+		case sampledCore:
+			return c.s, true
+		case *repeatCore:
+			core = c.Core
+		default:
+			return nil, false
+		}
+	}
+}
+
+// Close flushes the entries and delivers the pending notifications of
+// logger, as Sync does, waiting for the notifications until ctx is done. It
+// then stops the notification workers, and closes the sinks and the clients
+// opened by Zap: the file, the syslog connection, the sinks, see WithSink,
+// and the error reporter, see WithErrorReporter. Neither logger nor the
+// loggers derived from it may be used afterwards.
+//
+// logger must be created by Zap, or derived from such a logger with With,
+// Named or ForRequest, and not wrapped by zap.WrapCore.
+func Close(ctx context.Context, logger *zap.Logger) error {
+	s, ok := coreOf(logger)
+	if !ok {
+		return errors.New("zapx: Close of a logger not created by Zap")
+	}
+	if c, ok := logger.Core().(*repeatCore); ok {
+		c.r.flush()
+	}
+	s.res.once.Do(func() {
+		err := s.sync(ctx)
+		s.notifyPool.stop()
+		if s.errorReporter != nil {
+			err = multierr.Append(err, s.errorReporter.reporter.Close())
+		}
+		for _, c := range s.res.closers {
+			err = multierr.Append(err, c.Close())
+		}
+		s.res.err = err
+	})
+	return s.res.err
+}
@@ -0,0 +1,204 @@
+// Package cloudloggingx writes the zapx entries through the Cloud Logging
+// API, e.g. where no logging agent collects stdout.
+package cloudloggingx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/lixin9311/zapx"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+	logpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// logKeyOperation is the key of the operation of the entries, see
+// zapx.Operation.
+const logKeyOperation = "logging.googleapis.com/operation"
+
+func init() {
+	zapx.RegisterSink("cloudLogging", func(c zapx.SinkConfig) (zapx.Option, error) {
+		if c["logID"] == "" {
+			return nil, errors.New("cloudloggingx: cloudLogging needs a logID")
+		}
+		return WithSink(c["project"], c["logID"]), nil
+	})
+}
+
+// WithSink writes the entries to the log logID of projectID through the
+// Cloud Logging API, batched and asynchronously, instead of stdout. The
+// project defaults to the one of the logger, see zapx.WithProjectID. The
+// logger falls back to stdout if the client cannot be created. Sync flushes
+// the entries buffered. It is configured as the "cloudLogging" sink of
+// zapx.Config, with the "project" and "logID" keys.
+func WithSink(projectID, logID string) zapx.Option {
+	return zapx.WithOutputSink(func(env zapx.SinkEnv) (zapx.Sink, error) {
+		project := projectID
+		if project == "" {
+			project = env.Project
+		}
+		return newSink(project, logID, env.OnError)
+	})
+}
+
+// sink is a zapx.Sink sending the entries encoded by the core to the Cloud
+// Logging API, batched and asynchronously. The special fields that the
+// logging agent lifts out of the stdout lines, e.g. severity and trace, are
+// lifted into the LogEntry the same way.
+type sink struct {
+	client *logging.Client
+	logger *logging.Logger
+}
+
+func newSink(projectID, logID string, onError func(error)) (*sink, error) {
+	client, err := logging.NewClient(context.Background(), "projects/"+projectID)
+	if err != nil {
+		return nil, fmt.Errorf("cloudloggingx: failed to create the client: %w", err)
+	}
+	client.OnError = func(err error) {
+		onError(fmt.Errorf("cloudloggingx: failed to write to cloud logging: %w", err))
+	}
+	// the monitored resource is detected by the client, unless the entries
+	// carry one, see zapx.WithResourceDetection.
+	return &sink{client: client, logger: client.Logger(logID)}, nil
+}
+
+// Write sends the entry encoded in p.
+func (s *sink) Write(p []byte) (int, error) {
+	e, err := logEntry(p)
+	if err != nil {
+		return 0, err
+	}
+	s.logger.Log(e)
+	return len(p), nil
+}
+
+// Sync flushes the entries buffered.
+func (s *sink) Sync() error {
+	return s.logger.Flush()
+}
+
+// Close flushes the entries buffered and closes the client.
+func (s *sink) Close() error {
+	return s.client.Close()
+}
+
+// logEntry returns the LogEntry of an entry encoded by the core.
+func logEntry(p []byte) (logging.Entry, error) {
+	var e logging.Entry
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(p, &m); err != nil {
+		return e, err
+	}
+	take := func(key string, v interface{}) bool {
+		raw, ok := m[key]
+		if !ok {
+			return false
+		}
+		delete(m, key)
+		return json.Unmarshal(raw, v) == nil
+	}
+	var severity, eventTime string
+	if take(zapx.StackdriverEncoderConfig.LevelKey, &severity) {
+		e.Severity = logging.ParseSeverity(severity)
+	}
+	if take(zapx.StackdriverEncoderConfig.TimeKey, &eventTime) {
+		e.Timestamp, _ = parseEventTime(eventTime)
+	}
+	take("logging.googleapis.com/trace", &e.Trace)
+	take("logging.googleapis.com/spanId", &e.SpanID)
+	take("logging.googleapis.com/trace_sampled", &e.TraceSampled)
+	take("logging.googleapis.com/labels", &e.Labels)
+	var loc struct {
+		File     string `json:"file"`
+		Line     int64  `json:"line"`
+		Function string `json:"function"`
+	}
+	if take("logging.googleapis.com/sourceLocation", &loc) {
+		e.SourceLocation = &logpb.LogEntrySourceLocation{File: loc.File, Line: loc.Line, Function: loc.Function}
+	}
+	var op struct {
+		ID       string `json:"id"`
+		Producer string `json:"producer"`
+		First    bool   `json:"first"`
+		Last     bool   `json:"last"`
+	}
+	if take(logKeyOperation, &op) {
+		e.Operation = &logpb.LogEntryOperation{Id: op.ID, Producer: op.Producer, First: op.First, Last: op.Last}
+	}
+	var res struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	}
+	if take("resource", &res) {
+		e.Resource = &mrpb.MonitoredResource{Type: res.Type, Labels: res.Labels}
+	}
+	var req map[string]interface{}
+	if take("httpRequest", &req) {
+		e.HTTPRequest = httpRequest(req)
+	}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return e, err
+	}
+	e.Payload = json.RawMessage(payload)
+	return e, nil
+}
+
+// httpRequest converts an httpRequest field, see zapx.HTTPRequestEntry, back
+// into the request of a LogEntry.
+func httpRequest(m map[string]interface{}) *logging.HTTPRequest {
+	str := func(key string) string {
+		s, _ := m[key].(string)
+		return s
+	}
+	i64 := func(key string) int64 {
+		n, _ := strconv.ParseInt(str(key), 10, 64)
+		return n
+	}
+	r := &http.Request{Method: str("requestMethod"), Proto: str("protocol"), Header: make(http.Header)}
+	r.URL, _ = url.Parse(str("requestUrl"))
+	if r.URL == nil {
+		r.URL = &url.URL{}
+	}
+	if ua := str("userAgent"); ua != "" {
+		r.Header.Set("User-Agent", ua)
+	}
+	if ref := str("referer"); ref != "" {
+		r.Header.Set("Referer", ref)
+	}
+	req := &logging.HTTPRequest{
+		Request:        r,
+		RequestSize:    i64("requestSize"),
+		ResponseSize:   i64("responseSize"),
+		RemoteIP:       str("remoteIp"),
+		LocalIP:        str("serverIp"),
+		CacheFillBytes: i64("cacheFillBytes"),
+	}
+	req.CacheLookup, _ = m["cacheLookup"].(bool)
+	req.CacheHit, _ = m["cacheHit"].(bool)
+	req.CacheValidatedWithOriginServer, _ = m["cacheValidatedWithOriginServer"].(bool)
+	if status, ok := m["status"].(float64); ok {
+		req.Status = int(status)
+	}
+	if latency, err := strconv.ParseFloat(strings.TrimSuffix(str("latency"), "s"), 64); err == nil {
+		req.Latency = time.Duration(latency * float64(time.Second))
+	}
+	return req
+}
+
+// parseEventTime parses the time of an entry encoded by the zapx core, with
+// zapcore.ISO8601TimeEncoder or an RFC 3339 encoder.
+func parseEventTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02T15:04:05.000Z0700", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
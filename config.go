@@ -0,0 +1,238 @@
+package zapx
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config is the configuration of a logger, parallel to the options, to keep
+// complex setups in configuration files, see Build. It can be unmarshaled
+// from JSON or YAML, e.g.:
+//
+//	level: info
+//	service: api
+//	slack:
+//	  url: https://hooks.slack.com/services/...
+//	  level: error
+//	sampling:
+//	  initial: 100
+//	  thereafter: 100
+//	sinks:
+//	  cloudLogging:
+//	    logID: app
+type Config struct {
+	// Level is the minimum level of the entries, info by default.
+	Level zapcore.Level `json:"level" yaml:"level"`
+	// Development writes human readable entries, see WithDevelopment.
+	Development bool `json:"development" yaml:"development"`
+	// Service, Version and Project are set with WithService, WithVersion and
+	// WithProjectID.
+	Service string `json:"service" yaml:"service"`
+	Version string `json:"version" yaml:"version"`
+	Project string `json:"project" yaml:"project"`
+	// AutoDetect detects the project, the service and the version, see
+	// WithAutoDetect.
+	AutoDetect bool `json:"autoDetect" yaml:"autoDetect"`
+	// ResourceDetection detects the monitored resource, see
+	// WithResourceDetection.
+	ResourceDetection bool `json:"resourceDetection" yaml:"resourceDetection"`
+	// StacktraceLevel is set with WithStacktraceLevel.
+	StacktraceLevel *zapcore.Level `json:"stacktraceLevel" yaml:"stacktraceLevel"`
+	// DisableCaller does not capture the callers, see WithDisableCaller.
+	DisableCaller bool `json:"disableCaller" yaml:"disableCaller"`
+	// Labels are attached to every entry, see WithLabels.
+	Labels map[string]string `json:"labels" yaml:"labels"`
+	// FieldLevels are the minimum levels of the fields, keyed by field key,
+	// see WithFieldLevels.
+	FieldLevels map[string]zapcore.Level `json:"fieldLevels" yaml:"fieldLevels"`
+
+	Sampling  *SamplingConfig  `json:"sampling" yaml:"sampling"`
+	Slack     *SlackConfig     `json:"slack" yaml:"slack"`
+	PagerDuty *PagerDutyConfig `json:"pagerDuty" yaml:"pagerDuty"`
+	Email     *EmailConfig     `json:"email" yaml:"email"`
+	File      *FileConfig      `json:"file" yaml:"file"`
+	Syslog    *SyslogConfig    `json:"syslog" yaml:"syslog"`
+	Journald  *JournaldConfig  `json:"journald" yaml:"journald"`
+	Fluent    *FluentConfig    `json:"fluent" yaml:"fluent"`
+	// Sinks configures the sinks and the error reporters of the
+	// subpackages, by name, e.g. "cloudLogging" once cloudloggingx is
+	// imported, see RegisterSink.
+	Sinks map[string]SinkConfig `json:"sinks" yaml:"sinks"`
+}
+
+// SamplingConfig is the configuration of WithSampling.
+type SamplingConfig struct {
+	Initial    int `json:"initial" yaml:"initial"`
+	Thereafter int `json:"thereafter" yaml:"thereafter"`
+}
+
+// SlackConfig is the configuration of the Slack notifications: a webhook
+// url, see WithSlackURL, or a bot token and channel, see WithSlackToken.
+type SlackConfig struct {
+	URL     string `json:"url" yaml:"url"`
+	Token   string `json:"token" yaml:"token"`
+	Channel string `json:"channel" yaml:"channel"`
+	// Level notifies every entry at level or above, see WithSlackLevel.
+	Level *zapcore.Level `json:"level" yaml:"level"`
+}
+
+// PagerDutyConfig is the configuration of NewPagerDutyNotifier.
+type PagerDutyConfig struct {
+	RoutingKey string `json:"routingKey" yaml:"routingKey"`
+}
+
+// EmailConfig is the configuration of NewEmailNotifier. The server is
+// authenticated to with PLAIN if Username is set.
+type EmailConfig struct {
+	Addr     string          `json:"addr" yaml:"addr"`
+	From     string          `json:"from" yaml:"from"`
+	To       []string        `json:"to" yaml:"to"`
+	Username string          `json:"username" yaml:"username"`
+	Password string          `json:"password" yaml:"password"`
+	Levels   []zapcore.Level `json:"levels" yaml:"levels"`
+}
+
+// FileConfig is the configuration of WithFile.
+type FileConfig struct {
+	Path       string `json:"path" yaml:"path"`
+	MaxSizeMB  int    `json:"maxSizeMB" yaml:"maxSizeMB"`
+	MaxBackups int    `json:"maxBackups" yaml:"maxBackups"`
+	MaxAge     int    `json:"maxAge" yaml:"maxAge"`
+}
+
+// SyslogConfig is the configuration of WithSyslog.
+type SyslogConfig struct {
+	Network string `json:"network" yaml:"network"`
+	Addr    string `json:"addr" yaml:"addr"`
+	Tag     string `json:"tag" yaml:"tag"`
+}
+
+// JournaldConfig is the configuration of WithJournald.
+type JournaldConfig struct {
+	Identifier string `json:"identifier" yaml:"identifier"`
+}
+
+// FluentConfig is the configuration of WithFluentForward, the network
+// defaulting to tcp.
+type FluentConfig struct {
+	Network string `json:"network" yaml:"network"`
+	Addr    string `json:"addr" yaml:"addr"`
+	Tag     string `json:"tag" yaml:"tag"`
+}
+
+// Options returns the options of the configuration.
+func (c Config) Options() ([]Option, error) {
+	var opts []Option
+	if c.Development {
+		opts = append(opts, WithDevelopment())
+	}
+	if c.Service != "" {
+		opts = append(opts, WithService(c.Service))
+	}
+	if c.Version != "" {
+		opts = append(opts, WithVersion(c.Version))
+	}
+	if c.Project != "" {
+		opts = append(opts, WithProjectID(c.Project))
+	}
+	if c.AutoDetect {
+		opts = append(opts, WithAutoDetect())
+	}
+	if c.ResourceDetection {
+		opts = append(opts, WithResourceDetection())
+	}
+	if len(c.Labels) != 0 {
+		opts = append(opts, WithLabels(c.Labels))
+	}
+	if len(c.FieldLevels) != 0 {
+		opts = append(opts, WithFieldLevels(c.FieldLevels))
+	}
+	if c.DisableCaller {
+		opts = append(opts, WithDisableCaller())
+	}
+	if c.StacktraceLevel != nil {
+		opts = append(opts, WithStacktraceLevel(*c.StacktraceLevel))
+	}
+	if c.Sampling != nil {
+		opts = append(opts, WithSampling(c.Sampling.Initial, c.Sampling.Thereafter))
+	}
+	if s := c.Slack; s != nil {
+		if s.URL == "" && s.Token == "" {
+			return nil, errors.New("zapx: slack needs a url or a token")
+		}
+		if s.URL != "" {
+			opts = append(opts, WithSlackURL(s.URL))
+		}
+		if s.Token != "" {
+			opts = append(opts, WithSlackToken(s.Token, s.Channel))
+		}
+		if s.Level != nil {
+			opts = append(opts, WithSlackLevel(*s.Level))
+		}
+	}
+	if p := c.PagerDuty; p != nil {
+		if p.RoutingKey == "" {
+			return nil, errors.New("zapx: pagerDuty needs a routing key")
+		}
+		opts = append(opts, WithNotifier(NewPagerDutyNotifier(p.RoutingKey)))
+	}
+	if e := c.Email; e != nil {
+		if e.Addr == "" || len(e.To) == 0 {
+			return nil, errors.New("zapx: email needs an addr and recipients")
+		}
+		var eopts []EmailOption
+		if e.Username != "" {
+			host, _, err := net.SplitHostPort(e.Addr)
+			if err != nil {
+				return nil, err
+			}
+			eopts = append(eopts, EmailAuth(smtp.PlainAuth("", e.Username, e.Password, host)))
+		}
+		if len(e.Levels) != 0 {
+			eopts = append(eopts, EmailLevels(e.Levels...))
+		}
+		opts = append(opts, WithNotifier(NewEmailNotifier(e.Addr, e.From, e.To, eopts...)))
+	}
+	if f := c.File; f != nil {
+		if f.Path == "" {
+			return nil, errors.New("zapx: file needs a path")
+		}
+		opts = append(opts, WithFile(f.Path, f.MaxSizeMB, f.MaxBackups, f.MaxAge))
+	}
+	if s := c.Syslog; s != nil {
+		opts = append(opts, WithSyslog(s.Network, s.Addr, s.Tag))
+	}
+	if j := c.Journald; j != nil {
+		opts = append(opts, WithJournald(j.Identifier))
+	}
+	if f := c.Fluent; f != nil {
+		if f.Addr == "" || f.Tag == "" {
+			return nil, errors.New("zapx: fluent needs an addr and a tag")
+		}
+		network := f.Network
+		if network == "" {
+			network = "tcp"
+		}
+		opts = append(opts, WithFluentForward(network, f.Addr, f.Tag))
+	}
+	sinks, err := sinkOptions(c.Sinks)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, sinks...), nil
+}
+
+// Build returns the logger of the configuration, opts being applied after
+// the ones of the configuration, e.g. for the options that cannot be
+// configured from a file.
+func (c Config) Build(opts ...Option) (*zap.Logger, error) {
+	copts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return Zap(c.Level, append(copts, opts...)...), nil
+}
@@ -0,0 +1,52 @@
+package zapx
+
+import (
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// checkedTee is a tee writing to each core only the entries it enables, as
+// the stackdriver core writes to its parent without checking the entries.
+type checkedTee []zapcore.Core
+
+func (t checkedTee) Enabled(level zapcore.Level) bool {
+	for _, c := range t {
+		if c.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t checkedTee) With(fields []zapcore.Field) zapcore.Core {
+	cores := make(checkedTee, len(t))
+	for i, c := range t {
+		cores[i] = c.With(fields)
+	}
+	return cores
+}
+
+func (t checkedTee) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	for _, c := range t {
+		ce = c.Check(ent, ce)
+	}
+	return ce
+}
+
+func (t checkedTee) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var err error
+	for _, c := range t {
+		if c.Enabled(ent.Level) {
+			err = multierr.Append(err, c.Write(ent, fields))
+		}
+	}
+	return err
+}
+
+func (t checkedTee) Sync() error {
+	var err error
+	for _, c := range t {
+		err = multierr.Append(err, c.Sync())
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package zapx
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type loggerContextKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying logger, used by the
+// context-first logging functions, e.g. Info. Middlewares stash the logger of
+// the request there, see ForRequest, for the downstream code to retrieve it
+// with LoggerFromContext.
+func ContextWithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the logger set by ContextWithLogger, or the global
+// logger.
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*zap.Logger); ok && logger != nil {
+		return logger
+	}
+	return zap.L()
+}
+
+// CtxLogger is a logger whose methods take the context first and attach its
+// trace, see Context. The zero CtxLogger logs to the logger carried by the
+// context, see LoggerFromContext.
+type CtxLogger struct {
+	logger *zap.Logger
+}
+
+// NewCtxLogger returns a CtxLogger logging to logger.
+func NewCtxLogger(logger *zap.Logger) CtxLogger {
+	return CtxLogger{logger: logger}
+}
+
+// Debug logs a message at debug level, see Context.
+func (l CtxLogger) Debug(ctx context.Context, msg string, fields ...zapcore.Field) {
+	l.log(ctx, zapcore.DebugLevel, msg, fields)
+}
+
+// Info logs a message at info level, see Context.
+func (l CtxLogger) Info(ctx context.Context, msg string, fields ...zapcore.Field) {
+	l.log(ctx, zapcore.InfoLevel, msg, fields)
+}
+
+// Warn logs a message at warn level, see Context.
+func (l CtxLogger) Warn(ctx context.Context, msg string, fields ...zapcore.Field) {
+	l.log(ctx, zapcore.WarnLevel, msg, fields)
+}
+
+// Error logs a message at error level, see Context.
+func (l CtxLogger) Error(ctx context.Context, msg string, fields ...zapcore.Field) {
+	l.log(ctx, zapcore.ErrorLevel, msg, fields)
+}
+
+func (l CtxLogger) log(ctx context.Context, level zapcore.Level, msg string, fields []zapcore.Field) {
+	logger := l.logger
+	if logger == nil {
+		logger = LoggerFromContext(ctx)
+	}
+	// skip the exported method and log
+	ce := logger.WithOptions(zap.AddCallerSkip(2)).Check(level, msg)
+	if ce == nil {
+		return
+	}
+	fs := make([]zapcore.Field, 0, len(fields)+1)
+	fs = append(fs, Context(ctx))
+	ce.Write(append(fs, fields...)...)
+}
+
+// Debug logs a message at debug level to the logger carried by ctx, see
+// LoggerFromContext, attaching the trace of ctx, see Context.
+func Debug(ctx context.Context, msg string, fields ...zapcore.Field) {
+	CtxLogger{}.log(ctx, zapcore.DebugLevel, msg, fields)
+}
+
+// Info logs a message at info level to the logger carried by ctx, see
+// LoggerFromContext, attaching the trace of ctx, see Context.
+func Info(ctx context.Context, msg string, fields ...zapcore.Field) {
+	CtxLogger{}.log(ctx, zapcore.InfoLevel, msg, fields)
+}
+
+// Warn logs a message at warn level to the logger carried by ctx, see
+// LoggerFromContext, attaching the trace of ctx, see Context.
+func Warn(ctx context.Context, msg string, fields ...zapcore.Field) {
+	CtxLogger{}.log(ctx, zapcore.WarnLevel, msg, fields)
+}
+
+// Error logs a message at error level to the logger carried by ctx, see
+// LoggerFromContext, attaching the trace of ctx, see Context.
+func Error(ctx context.Context, msg string, fields ...zapcore.Field) {
+	CtxLogger{}.log(ctx, zapcore.ErrorLevel, msg, fields)
+}
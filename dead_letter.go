@@ -0,0 +1,92 @@
+package zapx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var errCircuitOpen = errors.New("zapx: notifications paused after consecutive failures")
+
+// DeadLetter is a notification that could not be delivered.
+type DeadLetter struct {
+	Time     time.Time              `json:"time"`
+	Level    string                 `json:"level"`
+	Message  string                 `json:"message"`
+	Caller   string                 `json:"caller,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	Notifier string                 `json:"notifier"`
+	Error    string                 `json:"error"`
+
+	// onError is the handler of the logger, for the failures of the dead
+	// letter sinks.
+	onError errorHandler
+}
+
+// DeadLetterFunc receives the notifications that could not be delivered, e.g.
+// to replay them later.
+type DeadLetterFunc func(DeadLetter)
+
+func newDeadLetter(n Notifier, ent zapcore.Entry, fields []zapcore.Field, err error) DeadLetter {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	dl := DeadLetter{
+		Time:     ent.Time,
+		Level:    ent.Level.String(),
+		Message:  ent.Message,
+		Fields:   enc.Fields,
+		Notifier: fmt.Sprintf("%T", n),
+		Error:    err.Error(),
+	}
+	if ent.Caller.Defined {
+		dl.Caller = ent.Caller.String()
+	}
+	return dl
+}
+
+// DeadLetterDir writes every dead letter as a JSON file in dir.
+func DeadLetterDir(dir string) DeadLetterFunc {
+	return func(dl DeadLetter) {
+		buf, err := json.Marshal(dl)
+		if err != nil {
+			dl.onError.errorf("zapx: failed to marshal dead letter: %w", err)
+			return
+		}
+		name := fmt.Sprintf("%s-%s.json", dl.Time.UTC().Format("20060102T150405.000000000"), newOperationID())
+		if err := os.WriteFile(filepath.Join(dir, name), buf, 0o644); err != nil {
+			dl.onError.errorf("zapx: failed to write dead letter: %w", err)
+		}
+	}
+}
+
+// DeadLetterFile appends every dead letter as a line of JSON to the file at
+// path.
+func DeadLetterFile(path string) DeadLetterFunc {
+	var mu sync.Mutex
+	return func(dl DeadLetter) {
+		buf, err := json.Marshal(dl)
+		if err != nil {
+			dl.onError.errorf("zapx: failed to marshal dead letter: %w", err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			dl.onError.errorf("zapx: failed to write dead letter: %w", err)
+			return
+		}
+		defer f.Close()
+		if _, err := f.Write(append(buf, '\n')); err != nil {
+			dl.onError.errorf("zapx: failed to write dead letter: %w", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package zapx
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type dedupEntry struct {
+	core   *stackdriver
+	ent    zapcore.Entry
+	fields []zapcore.Field
+	// count is the number of entries of the window, the first one written
+	// included.
+	count int64
+}
+
+func (e *dedupEntry) write() error {
+	return e.core.write(e.ent, append(e.fields, zap.Int64("occurrences", e.count)))
+}
+
+// deduper collapses identical consecutive entries written within a window.
+// It is shared by a logger and all of its children.
+type deduper struct {
+	mu      sync.Mutex
+	window  time.Duration
+	key     string
+	first   time.Time
+	pending *dedupEntry
+	clock   zapcore.Clock
+	// stop cancels the flush of the pending entry once the window expires.
+	stop func()
+}
+
+func newDeduper(window time.Duration, clock zapcore.Clock) *deduper {
+	return &deduper{window: window, clock: clock}
+}
+
+func dedupKey(ent zapcore.Entry) string {
+	return strconv.Itoa(int(ent.Level)) + "|" + ent.Caller.String() + "|" + ent.Message
+}
+
+// observe reports whether ent should be written, and returns the previously
+// suppressed entry, if any, that has to be flushed before it.
+func (d *deduper) observe(s *stackdriver, ent zapcore.Entry, fields []zapcore.Field) (*dedupEntry, bool) {
+	key := dedupKey(ent)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if key == d.key && ent.Time.Sub(d.first) < d.window {
+		// the first entry and this one
+		count := int64(2)
+		if d.pending != nil {
+			count = d.pending.count + 1
+		}
+		d.pending = &dedupEntry{
+			core:   s,
+			ent:    ent,
+			fields: append([]zapcore.Field(nil), fields...),
+			count:  count,
+		}
+		if d.stop == nil {
+			first := d.first
+			d.stop = afterFunc(d.clock, d.window-d.clock.Now().Sub(first), func() {
+				d.expire(first)
+			})
+		}
+		return nil, false
+	}
+	flush := d.pending
+	d.reset()
+	d.key = key
+	d.first = ent.Time
+	return flush, true
+}
+
+// expire writes the pending entry of the window started at first, if it is
+// still pending.
+func (d *deduper) expire(first time.Time) {
+	d.mu.Lock()
+	if !d.first.Equal(first) {
+		d.mu.Unlock()
+		return
+	}
+	flush := d.pending
+	d.reset()
+	d.mu.Unlock()
+	if flush != nil {
+		if err := flush.write(); err != nil {
+			flush.core.onError.errorf("zapx: failed to write deduplicated entry: %w", err)
+		}
+	}
+}
+
+// flush returns the pending suppressed entry and resets the state.
+func (d *deduper) flush() *dedupEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	flush := d.pending
+	d.reset()
+	return flush
+}
+
+// reset clears the window and the pending entry, d.mu held.
+func (d *deduper) reset() {
+	if d.stop != nil {
+		d.stop()
+		d.stop = nil
+	}
+	d.key = ""
+	d.pending = nil
+}
@@ -0,0 +1,93 @@
+package zapx
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// logN logs msg n times from the same caller, deduplicated entries sharing
+// their caller.
+func logN(logger *zap.Logger, msg string, n int) {
+	for i := 0; i < n; i++ {
+		logger.Info(msg)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	tests := []struct {
+		name string
+		// log writes the entries, with the clock and the logger.
+		log  func(clock *fakeClock, logger *zap.Logger)
+		want []int64 // the occurrences of the entries written, 0 if none
+	}{
+		{
+			name: "single entry",
+			log: func(clock *fakeClock, logger *zap.Logger) {
+				logN(logger, "a", 1)
+			},
+			want: []int64{0},
+		},
+		{
+			name: "repeats flushed once the window expires",
+			log: func(clock *fakeClock, logger *zap.Logger) {
+				logN(logger, "a", 5)
+				clock.Add(time.Minute)
+			},
+			want: []int64{0, 5},
+		},
+		{
+			name: "repeats flushed by a different entry",
+			log: func(clock *fakeClock, logger *zap.Logger) {
+				logN(logger, "a", 2)
+				logN(logger, "b", 1)
+			},
+			want: []int64{0, 2, 0},
+		},
+		{
+			name: "repeats flushed by Sync",
+			log: func(clock *fakeClock, logger *zap.Logger) {
+				logN(logger, "a", 3)
+				logger.Sync()
+			},
+			want: []int64{0, 3},
+		},
+		{
+			name: "new window after expiry",
+			log: func(clock *fakeClock, logger *zap.Logger) {
+				logN(logger, "a", 1)
+				clock.Add(time.Minute)
+				logN(logger, "a", 1)
+			},
+			want: []int64{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			obs, logs := observer.New(zapcore.DebugLevel)
+			logger := Zap(zapcore.DebugLevel,
+				WithOutput(zapcore.AddSync(ioutil.Discard)),
+				WithCores(obs),
+				WithClock(clock),
+				WithDedup(time.Minute),
+			)
+			tt.log(clock, logger)
+			eventually(t, func() bool { return logs.Len() >= len(tt.want) })
+			entries := logs.AllUntimed()
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(tt.want))
+			}
+			for i, e := range entries {
+				got, _ := e.ContextMap()["occurrences"].(int64)
+				if got != tt.want[i] {
+					t.Errorf("entry %d %q: occurrences = %d, want %d", i, e.Message, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package echox adapts the echo framework to zapx.
+package echox
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lixin9311/zapx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Middleware returns an echo middleware logging an httpRequest entry for
+// every request served, like zapx.HTTPMiddleware. The errors returned by the
+// handlers are passed to the error handler of echo, see ErrorHandler, before
+// the request is logged so that the entry carries the status of the error
+// response.
+func Middleware(logger *zap.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req, reqLogger := zapx.ForHTTPRequest(logger, c.Request())
+			c.SetRequest(req)
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			level := zapcore.InfoLevel
+			switch {
+			case res.Status >= 500:
+				level = zapcore.ErrorLevel
+			case res.Status >= 400:
+				level = zapcore.WarnLevel
+			}
+			if ce := reqLogger.Check(level, req.Method+" "+req.URL.Path); ce != nil {
+				entry := zapx.HTTPRequestEntry{
+					Request:       req,
+					RequestMethod: req.Method,
+					Status:        res.Status,
+					ResponseSize:  res.Size,
+					RemoteIP:      c.RealIP(),
+					Latency:       time.Since(start),
+				}
+				if req.ContentLength > 0 {
+					entry.RequestSize = req.ContentLength
+				}
+				ce.Write(zapx.Request(entry))
+			}
+			return nil
+		}
+	}
+}
+
+// ErrorHandler returns an echo.HTTPErrorHandler logging the errors of the
+// handlers resulting in a 5xx response, to the logger of the request, see
+// zapx.LoggerFromContext, before responding with next, e.g.
+// e.DefaultHTTPErrorHandler. The error logged is the internal error of an
+// echo.HTTPError if any, so that the error parser of the logger, see
+// zapx.WithErrorParser, and Error Reporting see the actual cause.
+func ErrorHandler(next echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		status := http.StatusInternalServerError
+		cause := err
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			status = he.Code
+			if he.Internal != nil {
+				cause = he.Internal
+			}
+		}
+		if status >= 500 && !c.Response().Committed {
+			zapx.LoggerFromContext(c.Request().Context()).Error(http.StatusText(status), zap.Error(cause))
+		}
+		next(err, c)
+	}
+}
@@ -0,0 +1,206 @@
+package zapx
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// EmailOption configures the Notifier returned by NewEmailNotifier.
+type EmailOption func(*emailNotifier)
+
+// EmailAuth authenticates to the SMTP server with auth, e.g.
+// smtp.PlainAuth.
+func EmailAuth(auth smtp.Auth) EmailOption {
+	return func(n *emailNotifier) {
+		n.auth = auth
+	}
+}
+
+// EmailLevels sets the levels of the entries sent, dpanic, panic and fatal by
+// default.
+func EmailLevels(levels ...zapcore.Level) EmailOption {
+	return func(n *emailNotifier) {
+		n.levels = make(map[zapcore.Level]bool, len(levels))
+		for _, l := range levels {
+			n.levels[l] = true
+		}
+	}
+}
+
+// EmailTLSConfig sets the TLS configuration used when the SMTP server supports
+// STARTTLS.
+func EmailTLSConfig(cfg *tls.Config) EmailOption {
+	return func(n *emailNotifier) {
+		n.tlsConfig = cfg
+	}
+}
+
+// emailNotifier sends the entries as HTML emails over SMTP.
+type emailNotifier struct {
+	addr      string
+	from      string
+	to        []string
+	auth      smtp.Auth
+	tlsConfig *tls.Config
+	levels    map[zapcore.Level]bool
+}
+
+// NewEmailNotifier returns a Notifier sending an HTML email from from to to,
+// through the SMTP server at addr, host:port, for the entries at dpanic,
+// panic and fatal level by default. The subject carries the service, the
+// version and the level of the entry.
+func NewEmailNotifier(addr, from string, to []string, opts ...EmailOption) Notifier {
+	n := &emailNotifier{
+		addr:   addr,
+		from:   from,
+		to:     to,
+		levels: map[zapcore.Level]bool{zapcore.DPanicLevel: true, zapcore.PanicLevel: true, zapcore.FatalLevel: true},
+	}
+	for _, o := range opts {
+		o(n)
+	}
+	return n
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<html><body>
+<h2>{{.Message}}</h2>
+<table>
+<tr><th align="left">Level</th><td>{{.Level}}</td></tr>
+<tr><th align="left">Service</th><td>{{.Service}}</td></tr>
+<tr><th align="left">Version</th><td>{{.Version}}</td></tr>
+<tr><th align="left">Time</th><td>{{.Time}}</td></tr>
+<tr><th align="left">Caller</th><td>{{.Caller}}</td></tr>
+</table>
+{{if .Fields}}<hr>
+<table>
+{{range .Fields}}<tr><th align="left" valign="top">{{.Key}}</th><td><pre>{{.Value}}</pre></td></tr>
+{{end}}</table>{{end}}
+</body></html>
+`))
+
+type emailField struct {
+	Key   string
+	Value string
+}
+
+// Notify is Notifier implementation.
+func (n *emailNotifier) Notify(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+	if !n.levels[ent.Level] {
+		return nil
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		if strings.HasPrefix(f.Key, "logging.googleapis.com/") || f.Key == "context" {
+			continue
+		}
+		f.AddTo(enc)
+	}
+	svc, _ := enc.Fields["serviceContext"].(map[string]interface{})
+	delete(enc.Fields, "serviceContext")
+	service, _ := svc["service"].(string)
+	version, _ := svc["version"].(string)
+
+	data := struct {
+		Message, Level, Service, Version, Time, Caller string
+		Fields                                         []emailField
+	}{
+		Message: ent.Message,
+		Level:   ent.Level.CapitalString(),
+		Service: service,
+		Version: version,
+		Time:    ent.Time.Format(time.RFC3339),
+		Caller:  ent.Caller.String(),
+	}
+	for key, val := range enc.Fields {
+		var s string
+		if str, ok := val.(string); ok {
+			s = str
+		} else if buf, err := json.MarshalIndent(val, "", "  "); err == nil {
+			s = string(buf)
+		} else {
+			s = fmt.Sprint(val)
+		}
+		data.Fields = append(data.Fields, emailField{Key: key, Value: s})
+	}
+	sort.Slice(data.Fields, func(i, j int) bool { return data.Fields[i].Key < data.Fields[j].Key })
+
+	var msg bytes.Buffer
+	subject := fmt.Sprintf("[%s %s] %s: %s", service, version, data.Level, ent.Message)
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", ent.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	if err := emailTemplate.Execute(&msg, data); err != nil {
+		return err
+	}
+	return n.send(ctx, msg.Bytes())
+}
+
+// send is smtp.SendMail honoring the deadline of ctx.
+func (n *emailNotifier) send(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(n.addr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		cfg := n.tlsConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: host}
+		}
+		if err := c.StartTLS(cfg); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
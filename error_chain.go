@@ -0,0 +1,63 @@
+package zapx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorChain constructs a field that carries the chain of err, as unwrapped
+// by errors.Unwrap, as an array with the type and the message of each link,
+// and its fields if it implements zapcore.ObjectMarshaler, so that a deeply
+// wrapped error can be diagnosed from the payload. See WithErrorChain to add
+// it to every error logged.
+func ErrorChain(err error) zapcore.Field {
+	return NamedErrorChain("error_chain", err)
+}
+
+// NamedErrorChain is ErrorChain with the key of the field.
+func NamedErrorChain(key string, err error) zapcore.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	return zap.Array(key, errorChain{err: err})
+}
+
+type errorChain struct {
+	err error
+}
+
+// MarshalLogArray is ArrayMarshaler implementation.
+func (c errorChain) MarshalLogArray(e zapcore.ArrayEncoder) error {
+	for err := c.err; err != nil; {
+		next := errors.Unwrap(err)
+		if err := e.AppendObject(errorLink{err: err, next: next}); err != nil {
+			return err
+		}
+		err = next
+	}
+	return nil
+}
+
+type errorLink struct {
+	err, next error
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (l errorLink) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("type", fmt.Sprintf("%T", l.err))
+	msg := l.err.Error()
+	if l.next != nil {
+		// keep the message of the link only, e.g. "read config" of
+		// "read config: file not found".
+		msg = strings.TrimSuffix(strings.TrimSuffix(msg, l.next.Error()), ": ")
+	}
+	e.AddString("message", msg)
+	if m, ok := l.err.(zapcore.ObjectMarshaler); ok {
+		return e.AddObject("fields", m)
+	}
+	return nil
+}
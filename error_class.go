@@ -0,0 +1,77 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/status"
+)
+
+const logKeyErrorClass = "zapx.error_class"
+
+type errorClass struct {
+	class string
+	// retryable is empty when unknown.
+	retryable string
+}
+
+// ErrorClass constructs a field that classifies err independently of its
+// message: by context cancellation, gRPC code, HTTP status (errors with a
+// StatusCode() int method), net timeout, and Retryable() bool when
+// implemented. The class is emitted as the "error.class" and
+// "error.retryable" labels.
+func ErrorClass(err error) zapcore.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	return zap.Reflect(logKeyErrorClass, classifyError(err))
+}
+
+type grpcStatusError interface {
+	GRPCStatus() *status.Status
+}
+
+type httpStatusError interface {
+	StatusCode() int
+}
+
+func classifyError(err error) errorClass {
+	c := errorClass{class: "unknown"}
+	var (
+		gerr grpcStatusError
+		herr httpStatusError
+		nerr net.Error
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		c.class = "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		c.class = "deadline_exceeded"
+	case errors.As(err, &gerr):
+		c.class = "grpc." + gerr.GRPCStatus().Code().String()
+	case errors.As(err, &herr):
+		c.class = "http." + strconv.Itoa(herr.StatusCode())
+	case errors.As(err, &nerr):
+		c.class = "net"
+		if nerr.Timeout() {
+			c.class = "net.timeout"
+		}
+	}
+	var rerr retryableError
+	if errors.As(err, &rerr) {
+		c.retryable = strconv.FormatBool(rerr.Retryable())
+	}
+	return c
+}
+
+func (c errorClass) labels() []zapcore.Field {
+	fs := []zapcore.Field{zap.String("error.class", c.class)}
+	if c.retryable != "" {
+		fs = append(fs, zap.String("error.retryable", c.retryable))
+	}
+	return fs
+}
@@ -0,0 +1,39 @@
+package zapx
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorParserFor returns an error parser, see WithErrorParser, rendering the
+// errors whose chain has an error of type T, as found by errors.As, e.g.
+// *pgconn.PgError, with parse.
+func ErrorParserFor[T error](parse func(T) (zapcore.ObjectMarshaler, bool)) func(error) (zapcore.ObjectMarshaler, bool) {
+	return func(err error) (zapcore.ObjectMarshaler, bool) {
+		var target T
+		if !errors.As(err, &target) {
+			return nil, false
+		}
+		return parse(target)
+	}
+}
+
+// chainErrorParsers returns an error parser trying parsers in order, or nil
+// if there is none.
+func chainErrorParsers(parsers []func(error) (zapcore.ObjectMarshaler, bool)) func(error) (zapcore.ObjectMarshaler, bool) {
+	switch len(parsers) {
+	case 0:
+		return nil
+	case 1:
+		return parsers[0]
+	}
+	return func(err error) (zapcore.ObjectMarshaler, bool) {
+		for _, parse := range parsers {
+			if obj, ok := parse(err); ok {
+				return obj, true
+			}
+		}
+		return nil, false
+	}
+}
@@ -0,0 +1,118 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorEvent is an entry at error level or above reported to an
+// ErrorReporter, redacted, see WithRedaction.
+type ErrorEvent struct {
+	// Entry is the entry reported.
+	Entry zapcore.Entry
+	// Error is the message of the entry, wrapping the error of the entry if
+	// any.
+	Error error
+	// User is the user of the entry, see ContextWithUser.
+	User string
+	// Request is the request of the entry, if any, see Request.
+	Request *http.Request
+	// Stack is the stack trace of the entry, or else its caller, formatted
+	// as by runtime.Stack.
+	Stack []byte
+}
+
+// ErrorReporter reports the entries at error level or above, e.g. to the
+// Error Reporting API with errorreportingx, see WithErrorReporter.
+type ErrorReporter interface {
+	// Report reports e asynchronously.
+	Report(e ErrorEvent)
+	// ReportSync reports e before returning, for the entries terminating the
+	// process.
+	ReportSync(ctx context.Context, e ErrorEvent) error
+	// Flush delivers the events buffered, see Sync.
+	Flush()
+	// Close flushes the events buffered and releases the reporter, see Close.
+	Close() error
+}
+
+// errorReporter reports the entries to an ErrorReporter.
+type errorReporter struct {
+	reporter ErrorReporter
+	// redactor and reqRedaction redact the errors and the requests
+	// reported, if set.
+	redactor     *redactor
+	reqRedaction *requestRedaction
+	onError      errorHandler
+}
+
+func newErrorReporter(reporter ErrorReporter, redactor *redactor, reqRedaction *requestRedaction, onError errorHandler) *errorReporter {
+	return &errorReporter{reporter: reporter, redactor: redactor, reqRedaction: reqRedaction, onError: onError}
+}
+
+// report reports ent, fields being the fields of the entry as passed to the
+// core: the error, the request and the stack trace of the entry are looked up
+// there. The entries terminating the process are delivered before report
+// returns.
+func (r *errorReporter) report(ent zapcore.Entry, fields []zapcore.Field, user string, terminates bool) {
+	e := r.event(ent, fields, user)
+	if terminates {
+		// the process is about to terminate, deliver it before it does.
+		ctx, cancel := context.WithTimeout(context.Background(), slackFatalTimeout)
+		defer cancel()
+		if err := r.reporter.ReportSync(ctx, e); err != nil {
+			r.onError.errorf("zapx: failed to report error: %w", err)
+		}
+		return
+	}
+	r.reporter.Report(e)
+}
+
+// event returns the event of ent.
+func (r *errorReporter) event(ent zapcore.Entry, fields []zapcore.Field, user string) ErrorEvent {
+	e := ErrorEvent{Entry: ent, User: user}
+	var cause error
+	for _, f := range fields {
+		switch {
+		case f.Type == zapcore.ErrorType && cause == nil:
+			cause, _ = f.Interface.(error)
+		case f.Key == "stack_trace" && f.Type == zapcore.StringType:
+			// the message is prepended by the reporter
+			e.Stack = []byte(strings.TrimPrefix(f.String, "\n"))
+		case f.Key == "httpRequest":
+			if req, ok := f.Interface.(HTTPRequestEntry); ok {
+				e.Request = req.Request
+				if r.reqRedaction != nil {
+					e.Request = r.reqRedaction.request(e.Request)
+				}
+			}
+		}
+	}
+	if e.Stack == nil && ent.Caller.Defined {
+		// group the error by its caller rather than by the stack of the
+		// logger.
+		e.Stack = []byte(fmt.Sprintf("goroutine 1 [running]:\n%s()\n\t%s:%d\n", ent.Caller.Function, ent.Caller.File, ent.Caller.Line))
+	}
+	if cause != nil {
+		e.Error = fmt.Errorf("%s: %w", ent.Message, cause)
+	} else {
+		e.Error = errors.New(ent.Message)
+	}
+	if r.redactor != nil {
+		e.Error = redactedError{err: e.Error, msg: r.redactor.string(e.Error.Error())}
+		e.User = r.redactor.string(e.User)
+	}
+	return e
+}
+
+// flush delivers the errors buffered.
+func (r *errorReporter) flush() {
+	if r != nil {
+		r.reporter.Flush()
+	}
+}
@@ -0,0 +1,131 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// testReporter is an ErrorReporter recording the events reported.
+type testReporter struct {
+	mu     sync.Mutex
+	events []ErrorEvent
+	synced []bool
+}
+
+func (r *testReporter) Report(e ErrorEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	r.synced = append(r.synced, false)
+}
+
+func (r *testReporter) ReportSync(ctx context.Context, e ErrorEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	r.synced = append(r.synced, true)
+	return nil
+}
+
+func (r *testReporter) Flush() {}
+
+func (r *testReporter) Close() error { return nil }
+
+func TestErrorReporter(t *testing.T) {
+	req := httptest.NewRequest("GET", "/search?token=secret", nil)
+	tests := []struct {
+		name      string
+		log       func(logger *zap.Logger)
+		wantError string
+		wantUser  string
+		wantQuery string
+		wantSync  bool
+	}{
+		{
+			name:      "message",
+			log:       func(logger *zap.Logger) { logger.Error("failed") },
+			wantError: "failed",
+		},
+		{
+			name:      "error",
+			log:       func(logger *zap.Logger) { logger.Error("failed", zap.Error(errors.New("boom"))) },
+			wantError: "failed: boom",
+		},
+		{
+			name: "redacted",
+			log: func(logger *zap.Logger) {
+				ctx := ContextWithUser(context.Background(), "alice@example.com")
+				ForRequest(logger, ctx).Error("failed for alice@example.com")
+			},
+			wantError: "failed for [REDACTED]",
+			wantUser:  "[REDACTED]",
+		},
+		{
+			name: "request",
+			log: func(logger *zap.Logger) {
+				logger.Error("failed", Request(HTTPRequestEntry{Request: req}))
+			},
+			wantError: "failed",
+			wantQuery: "token=[REDACTED]",
+		},
+		{
+			name: "panic reported before terminating",
+			log: func(logger *zap.Logger) {
+				defer func() { recover() }()
+				logger.Panic("failed")
+			},
+			wantError: "failed",
+			wantSync:  true,
+		},
+		{
+			name: "below error level",
+			log:  func(logger *zap.Logger) { logger.Warn("failed") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(testReporter)
+			logger := Zap(zapcore.DebugLevel,
+				WithOutput(zapcore.AddSync(ioutil.Discard)),
+				WithRedaction(nil, regexp.MustCompile(`[a-z]+@example\.com`)),
+				WithRedactedQueryParams("token"),
+				WithErrorReporter(func(SinkEnv) (ErrorReporter, error) { return r, nil }),
+			)
+			tt.log(logger)
+			if tt.wantError == "" {
+				if len(r.events) != 0 {
+					t.Fatalf("reported %d events", len(r.events))
+				}
+				return
+			}
+			if len(r.events) != 1 {
+				t.Fatalf("reported %d events, want 1", len(r.events))
+			}
+			e := r.events[0]
+			if e.Error.Error() != tt.wantError {
+				t.Errorf("error = %q, want %q", e.Error, tt.wantError)
+			}
+			if e.User != tt.wantUser {
+				t.Errorf("user = %q, want %q", e.User, tt.wantUser)
+			}
+			if tt.wantQuery != "" && (e.Request == nil || !strings.Contains(e.Request.URL.RawQuery, tt.wantQuery)) {
+				t.Errorf("request = %v, want the query %q", e.Request, tt.wantQuery)
+			}
+			if len(e.Stack) == 0 {
+				t.Error("no stack")
+			}
+			if r.synced[0] != tt.wantSync {
+				t.Errorf("synchronous = %v, want %v", r.synced[0], tt.wantSync)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package zapx
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// errorStack returns the stack trace carried by the first error of fields,
+// where the innermost error of the chain carrying one was created, in the
+// format of the stack traces of zap, or an empty string if none does. The
+// errors of github.com/pkg/errors carry one, as do the errors with a
+// Callers() []uintptr method.
+func errorStack(fields []zapcore.Field) string {
+	for _, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		err, ok := f.Interface.(error)
+		if !ok {
+			continue
+		}
+		var pcs []uintptr
+		for ; err != nil; err = errors.Unwrap(err) {
+			if st := stackPCs(err); len(st) != 0 {
+				pcs = st
+			}
+		}
+		if len(pcs) != 0 {
+			return formatStack(pcs)
+		}
+	}
+	return ""
+}
+
+// stackPCs returns the program counters of the stack carried by err.
+func stackPCs(err error) []uintptr {
+	if c, ok := err.(interface{ Callers() []uintptr }); ok {
+		return c.Callers()
+	}
+	// pkg/errors returns a StackTrace, a []Frame of uintptr, looked up by
+	// reflection to not depend on it.
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	if t := m.Type().Out(0); t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	st := m.Call(nil)[0]
+	pcs := make([]uintptr, st.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(st.Index(i).Uint())
+	}
+	return pcs
+}
+
+// formatStack formats the stack of pcs as zap does.
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			if b.Len() != 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(frame.Function)
+			b.WriteString("\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+		}
+		if !more {
+			return b.String()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package errorreportingx reports the zapx entries at error level or above
+// to the Error Reporting API, e.g. when the logs are shipped to another
+// aggregator than Cloud Logging.
+package errorreportingx
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/errorreporting"
+	"github.com/lixin9311/zapx"
+)
+
+func init() {
+	zapx.RegisterSink("errorReporting", func(c zapx.SinkConfig) (zapx.Option, error) {
+		return WithErrorReporting(c["project"]), nil
+	})
+}
+
+// WithErrorReporting reports the entries at error level or above to the
+// Error Reporting API of projectID, with the service context, the user, the
+// request and the stack trace of the entry, see zapx.WithErrorReporter. The
+// project defaults to the one of the logger, see zapx.WithProjectID. It is
+// configured as the "errorReporting" sink of zapx.Config, with the "project"
+// key.
+func WithErrorReporting(projectID string) zapx.Option {
+	return zapx.WithErrorReporter(func(env zapx.SinkEnv) (zapx.ErrorReporter, error) {
+		project := projectID
+		if project == "" {
+			project = env.Project
+		}
+		client, err := errorreporting.NewClient(context.Background(), project, errorreporting.Config{
+			ServiceName:    env.ServiceContext.Service,
+			ServiceVersion: env.ServiceContext.Version,
+			OnError: func(err error) {
+				env.OnError(fmt.Errorf("errorreportingx: failed to report error: %w", err))
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("errorreportingx: failed to create the client: %w", err)
+		}
+		return reporter{client}, nil
+	})
+}
+
+// reporter is a zapx.ErrorReporter reporting to the Error Reporting API.
+type reporter struct {
+	client *errorreporting.Client
+}
+
+// Report reports e asynchronously.
+func (r reporter) Report(e zapx.ErrorEvent) {
+	r.client.Report(entry(e))
+}
+
+// ReportSync reports e before returning.
+func (r reporter) ReportSync(ctx context.Context, e zapx.ErrorEvent) error {
+	return r.client.ReportSync(ctx, entry(e))
+}
+
+// Flush delivers the errors buffered.
+func (r reporter) Flush() {
+	r.client.Flush()
+}
+
+// Close delivers the errors buffered and closes the client.
+func (r reporter) Close() error {
+	return r.client.Close()
+}
+
+func entry(e zapx.ErrorEvent) errorreporting.Entry {
+	return errorreporting.Entry{Error: e.Error, Req: e.Request, User: e.User, Stack: e.Stack}
+}
@@ -0,0 +1,71 @@
+package zapx
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	logKeyEvent        = "zapx.event"
+	logKeyEventPayload = "event"
+	eventNameLabel     = "event.name"
+)
+
+// TypedEvent is a structured event with a stable name, emitted by Event.
+type TypedEvent interface {
+	zapcore.ObjectMarshaler
+	EventName() string
+}
+
+var eventRegistry = struct {
+	sync.RWMutex
+	required map[string][]string
+}{required: make(map[string][]string)}
+
+// RegisterEvent registers the event name along with the keys its payload
+// must always contain. Entries of unregistered events, or with missing keys,
+// are still written but flagged under "event.invalid".
+func RegisterEvent(name string, required ...string) {
+	eventRegistry.Lock()
+	defer eventRegistry.Unlock()
+	eventRegistry.required[name] = required
+}
+
+// Event logs ev at info level. The entry carries the payload under "event"
+// and the event name as the "event.name" label.
+func Event(logger *zap.Logger, ev TypedEvent, fields ...zapcore.Field) {
+	fs := append(fields[:len(fields):len(fields)], zap.Reflect(logKeyEvent, ev))
+	logger.WithOptions(zap.AddCallerSkip(1)).Info(ev.EventName(), fs...)
+}
+
+// validateEvent returns the reason ev is invalid, or an empty string.
+func validateEvent(ev TypedEvent) string {
+	eventRegistry.RLock()
+	required, ok := eventRegistry.required[ev.EventName()]
+	eventRegistry.RUnlock()
+	if !ok {
+		return "unregistered event"
+	}
+	if len(required) == 0 {
+		return ""
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	if err := ev.MarshalLogObject(enc); err != nil {
+		return err.Error()
+	}
+	var missing string
+	for _, key := range required {
+		if _, ok := enc.Fields[key]; !ok {
+			if missing != "" {
+				missing += ", "
+			}
+			missing += key
+		}
+	}
+	if missing != "" {
+		return "missing required fields: " + missing
+	}
+	return ""
+}
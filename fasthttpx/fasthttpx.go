@@ -0,0 +1,41 @@
+// Package fasthttpx adapts fasthttp requests to zapx.
+package fasthttpx
+
+import (
+	"time"
+
+	"github.com/lixin9311/zapx"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap/zapcore"
+)
+
+// Request constructs the same httpRequest field as zapx.Request from a
+// fasthttp request. If status is 0, the status code of the response is used.
+func Request(ctx *fasthttp.RequestCtx, status int, latency time.Duration) zapcore.Field {
+	if status == 0 {
+		status = ctx.Response.StatusCode()
+	}
+	return zapx.Request(zapx.HTTPRequestEntry{
+		RequestMethod: string(ctx.Method()),
+		RequestURL:    string(ctx.RequestURI()),
+		RequestSize:   int64(len(ctx.Request.Header.Header()) + len(ctx.Request.Body())),
+		Status:        status,
+		ResponseSize:  int64(len(ctx.Response.Body())),
+		UserAgent:     string(ctx.UserAgent()),
+		RemoteIP:      remoteIP(ctx),
+		Referer:       string(ctx.Referer()),
+		Latency:       latency,
+	})
+}
+
+func remoteIP(ctx *fasthttp.RequestCtx) string {
+	if fwd := ctx.Request.Header.Peek("X-Forwarded-For"); len(fwd) != 0 {
+		for i := range fwd {
+			if fwd[i] == ',' {
+				return string(fwd[:i])
+			}
+		}
+		return string(fwd)
+	}
+	return ctx.RemoteIP().String()
+}
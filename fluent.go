@@ -0,0 +1,430 @@
+package zapx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// fluentBatchSize is the number of records buffered before they are
+	// forwarded.
+	fluentBatchSize = 256
+	// fluentMaxBuffer is the number of records buffered above which the
+	// writes block, until the records are forwarded.
+	fluentMaxBuffer = 8192
+	// fluentBlockTimeout bounds the time a write blocks on a full buffer,
+	// after which its record is dropped.
+	fluentBlockTimeout = time.Second
+	// fluentFlushInterval is the period of the forwarding of the records
+	// buffered.
+	fluentFlushInterval = time.Second
+	// fluentTimeout bounds the connection, the write and the ack of a chunk.
+	fluentTimeout = 5 * time.Second
+)
+
+var errFluentBufferFull = errors.New("zapx: fluent buffer full, dropping entry")
+
+// fluentSink is a zapcore.WriteSyncer forwarding the entries encoded by the
+// core to fluentd or Fluent Bit over the Forward protocol, in chunks
+// acknowledged by the server. The records are buffered until acknowledged,
+// reconnecting on failure, and the writes block while the buffer is full.
+type fluentSink struct {
+	network, addr string
+	tag           string
+	onError       errorHandler
+
+	mu      sync.Mutex
+	records [][]byte
+	// slots bounds the records buffered, one per record.
+	slots   chan struct{}
+	full    chan struct{}
+	done    chan struct{}
+	stopped sync.WaitGroup
+
+	// sendMu serializes the chunks on the connection.
+	sendMu sync.Mutex
+	conn   net.Conn
+}
+
+// newFluentSink returns a sink forwarding to the server at addr on network,
+// "tcp" or "unix", under tag.
+func newFluentSink(network, addr, tag string, onError errorHandler) (*fluentSink, error) {
+	s := &fluentSink{
+		network: network,
+		addr:    addr,
+		tag:     tag,
+		onError: onError,
+		slots:   make(chan struct{}, fluentMaxBuffer),
+		full:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	s.stopped.Add(1)
+	go s.run()
+	return s, nil
+}
+
+func (s *fluentSink) connect() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	conn, err := net.DialTimeout(s.network, s.addr, fluentTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// run forwards the records buffered periodically, and once the batch is
+// full.
+func (s *fluentSink) run() {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(fluentFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.full:
+		case <-s.done:
+			return
+		}
+		if err := s.Sync(); err != nil {
+			s.onError.errorf("zapx: failed to forward log entries: %w", err)
+		}
+	}
+}
+
+// Write buffers the record of the entry encoded in p, blocking while the
+// buffer is full.
+func (s *fluentSink) Write(p []byte) (int, error) {
+	record, err := fluentRecord(p)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		timer := time.NewTimer(fluentBlockTimeout)
+		defer timer.Stop()
+		select {
+		case s.slots <- struct{}{}:
+		case <-timer.C:
+			return 0, errFluentBufferFull
+		}
+	}
+	s.mu.Lock()
+	s.records = append(s.records, record)
+	full := len(s.records) >= fluentBatchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Sync forwards the records buffered. They remain buffered if they are not
+// acknowledged.
+func (s *fluentSink) Sync() error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	records := s.records
+	s.records = nil
+	s.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+	err := s.forward(records)
+	if err != nil {
+		// retried with the next chunk
+		s.mu.Lock()
+		s.records = append(records, s.records...)
+		s.mu.Unlock()
+		return err
+	}
+	for range records {
+		<-s.slots
+	}
+	return nil
+}
+
+// forward sends the records in a chunk, reconnecting once on failure.
+func (s *fluentSink) forward(records [][]byte) error {
+	chunk, msg := fluentMessage(s.tag, records)
+	var err error
+	for i := 0; i < 2; i++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				continue
+			}
+		}
+		if err = s.send(chunk, msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// send writes msg on the connection and waits for the ack of chunk.
+func (s *fluentSink) send(chunk string, msg []byte) error {
+	s.conn.SetDeadline(time.Now().Add(fluentTimeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		return err
+	}
+	ack, err := fluentAck(bufio.NewReader(s.conn))
+	if err != nil {
+		return err
+	}
+	if ack != chunk {
+		return fmt.Errorf("zapx: fluent ack %q does not match chunk %q", ack, chunk)
+	}
+	return nil
+}
+
+// Close forwards the records buffered and closes the connection. The records
+// not acknowledged are dropped.
+func (s *fluentSink) Close() error {
+	close(s.done)
+	s.stopped.Wait()
+	err := s.Sync()
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// fluentRecord returns the [time, record] entry of the Forward protocol of an
+// entry encoded by the core, msgpack encoded.
+func fluentRecord(p []byte) ([]byte, error) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	ts := time.Now()
+	if s, ok := m[StackdriverEncoderConfig.TimeKey].(string); ok {
+		if t, err := parseEventTime(s); err == nil {
+			ts = t
+		}
+	}
+	var b bytes.Buffer
+	b.WriteByte(0x92)
+	msgpackEventTime(&b, ts)
+	msgpackValue(&b, m)
+	return b.Bytes(), nil
+}
+
+// fluentMessage returns the Forward mode message of records, requesting the
+// ack of the returned chunk id.
+func fluentMessage(tag string, records [][]byte) (string, []byte) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	chunk := base64.StdEncoding.EncodeToString(id)
+	var b bytes.Buffer
+	b.WriteByte(0x93)
+	msgpackString(&b, tag)
+	msgpackHeader(&b, len(records), 0x90, 0xdc, 0xdd)
+	for _, r := range records {
+		b.Write(r)
+	}
+	msgpackHeader(&b, 2, 0x80, 0xde, 0xdf)
+	msgpackString(&b, "chunk")
+	msgpackString(&b, chunk)
+	msgpackString(&b, "size")
+	msgpackValue(&b, json.Number(strconv.Itoa(len(records))))
+	return chunk, b.Bytes()
+}
+
+// fluentAck reads the ack response of the server, a map holding the chunk id
+// under "ack".
+func fluentAck(r *bufio.Reader) (string, error) {
+	n, err := msgpackReadHeader(r, 0x80, 0xde, 0xdf)
+	if err != nil {
+		return "", err
+	}
+	var ack string
+	for i := 0; i < n; i++ {
+		key, err := msgpackReadString(r)
+		if err != nil {
+			return "", err
+		}
+		val, err := msgpackReadString(r)
+		if err != nil {
+			return "", err
+		}
+		if key == "ack" {
+			ack = val
+		}
+	}
+	return ack, nil
+}
+
+// msgpackEventTime appends t as the EventTime extension of the Forward
+// protocol: seconds and nanoseconds, big endian.
+func msgpackEventTime(b *bytes.Buffer, t time.Time) {
+	var buf [10]byte
+	buf[0], buf[1] = 0xd7, 0x00
+	binary.BigEndian.PutUint32(buf[2:], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(buf[6:], uint32(t.Nanosecond()))
+	b.Write(buf[:])
+}
+
+// msgpackValue appends the msgpack encoding of a value decoded from JSON,
+// with the numbers as json.Number.
+func msgpackValue(b *bytes.Buffer, val interface{}) {
+	switch v := val.(type) {
+	case nil:
+		b.WriteByte(0xc0)
+	case bool:
+		if v {
+			b.WriteByte(0xc3)
+		} else {
+			b.WriteByte(0xc2)
+		}
+	case string:
+		msgpackString(b, v)
+	case json.Number:
+		var buf [9]byte
+		if i, err := v.Int64(); err == nil {
+			if i >= -32 && i < 128 {
+				b.WriteByte(byte(i))
+				return
+			}
+			buf[0] = 0xd3
+			binary.BigEndian.PutUint64(buf[1:], uint64(i))
+		} else {
+			f, _ := v.Float64()
+			buf[0] = 0xcb
+			binary.BigEndian.PutUint64(buf[1:], math.Float64bits(f))
+		}
+		b.Write(buf[:])
+	case []interface{}:
+		msgpackHeader(b, len(v), 0x90, 0xdc, 0xdd)
+		for _, elem := range v {
+			msgpackValue(b, elem)
+		}
+	case map[string]interface{}:
+		msgpackHeader(b, len(v), 0x80, 0xde, 0xdf)
+		for key, elem := range v {
+			msgpackString(b, key)
+			msgpackValue(b, elem)
+		}
+	default:
+		msgpackString(b, fmt.Sprint(v))
+	}
+}
+
+func msgpackString(b *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n < 32:
+		b.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		b.WriteByte(0xd9)
+		b.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		b.WriteByte(0xda)
+		binary.Write(b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(0xdb)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+	b.WriteString(s)
+}
+
+// msgpackHeader appends the header of an array or a map of n elements, fix
+// being the fixarray or fixmap prefix.
+func msgpackHeader(b *bytes.Buffer, n int, fix, code16, code32 byte) {
+	switch {
+	case n < 16:
+		b.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		b.WriteByte(code16)
+		binary.Write(b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(code32)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+}
+
+// msgpackReadHeader reads the header of an array or a map, see msgpackHeader.
+func msgpackReadHeader(r *bufio.Reader, fix, code16, code32 byte) (int, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xf0 == fix:
+		return int(c & 0x0f), nil
+	case c == code16:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	case c == code32:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	}
+	return 0, fmt.Errorf("zapx: unexpected msgpack type 0x%x", c)
+}
+
+func msgpackReadString(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	var n int
+	switch {
+	case c&0xe0 == 0xa0:
+		n = int(c & 0x1f)
+	case c == 0xd9:
+		l, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		n = int(l)
+	case c == 0xda:
+		var l uint16
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+			return "", err
+		}
+		n = int(l)
+	case c == 0xdb:
+		var l uint32
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+			return "", err
+		}
+		n = int(l)
+	default:
+		return "", fmt.Errorf("zapx: unexpected msgpack type 0x%x", c)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,260 @@
+package zapx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a grpc interceptor logging the start, at
+// debug level, and the end of every unary call with its method, duration,
+// status code, peer address, trace, see Context, and metadata, see Metadata.
+// The handlers find a logger carrying the trace, request id and user of the
+// call in the context, see ForRequest and LoggerFromContext. A panicking
+// handler fails with codes.Internal, the call being logged with the panic and
+// its stack trace.
+func UnaryServerInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		start := time.Now()
+		ctx, callLogger := grpcServerCall(ctx, logger, info.FullMethod)
+		callLogger.Debug("started unary call", Metadata(ctx))
+		p, err := grpcRecover(func() (err error) {
+			resp, err = handler(ctx, req)
+			return err
+		})
+		grpcServerDone(ctx, callLogger, "finished unary call", start, err, p)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func StreamServerInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, callLogger := grpcServerCall(ss.Context(), logger, info.FullMethod)
+		callLogger.Debug("started streaming call", Metadata(ctx))
+		p, err := grpcRecover(func() error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+		grpcServerDone(ctx, callLogger, "finished streaming call", start, err, p)
+		return err
+	}
+}
+
+// serverStream is a grpc.ServerStream with its context replaced.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcServerCall returns the logger of a call and the context carrying it.
+func grpcServerCall(ctx context.Context, logger *zap.Logger, method string) (context.Context, *zap.Logger) {
+	fields := []zapcore.Field{zap.String("grpc.method", method)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer.address", p.Addr.String()))
+	}
+	callLogger := ForRequest(logger, ctx, fields...)
+	return ContextWithLogger(ctx, callLogger), callLogger
+}
+
+// grpcServerDone logs the end of a call, with the panic of its handler if p
+// is set, see grpcRecover.
+func grpcServerDone(ctx context.Context, logger *zap.Logger, msg string, start time.Time, err error, p *grpcPanic) {
+	code := status.Code(err)
+	if p != nil {
+		// reported by Error Reporting as a panic, see Recover.
+		msg = "panic: " + fmt.Sprint(p.value)
+	}
+	if ce := logger.Check(grpcCodeLevel(code), msg); ce != nil {
+		fields := []zapcore.Field{
+			zap.String("grpc.code", code.String()),
+			SinceMS("grpc.duration", start),
+			Metadata(ctx),
+			If(err != nil, zap.Error(err)),
+		}
+		if p != nil {
+			fields = append(fields,
+				zap.String("panic", fmt.Sprint(p.value)),
+				zap.String("stack_trace", panicStack(p.stack)),
+			)
+		}
+		ce.Write(fields...)
+	}
+}
+
+// grpcPanic is the panic of a handler, with the stack where it panicked.
+type grpcPanic struct {
+	value interface{}
+	stack string
+}
+
+// grpcRecover calls f, turning a panic into a codes.Internal error, the panic
+// being returned to be logged with the end of the call.
+func grpcRecover(f func() error) (p *grpcPanic, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			_, stack := panicSite()
+			p = &grpcPanic{value: r, stack: stack}
+			err = status.Errorf(codes.Internal, "panic: %v", r)
+		}
+	}()
+	return nil, f()
+}
+
+// grpcCodeLevel returns the level of the calls ending with code: info for
+// the successful calls, warn for the errors caused by the client and error
+// otherwise.
+func grpcCodeLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zapcore.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition,
+		codes.OutOfRange, codes.ResourceExhausted, codes.Aborted:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// UnaryClientInterceptor returns a grpc interceptor logging every outgoing
+// unary call with its target, method, latency, status code and trace, see
+// Context. The request id of the context, see Context, is forwarded in the
+// metadata, under the first key of WithRequestIDKeys or x-request-id, unless
+// set already.
+func UnaryClientInterceptor(logger *zap.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		ctx = outgoingRequestID(ctx, logger)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		grpcClientDone(ctx, logger, "finished client unary call", cc.Target(), method, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor is UnaryClientInterceptor for streaming calls,
+// logged once the stream ends, or once its context is done for the streams
+// dropped before their end.
+func StreamClientInterceptor(logger *zap.Logger) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx = outgoingRequestID(ctx, logger)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			grpcClientDone(ctx, logger, "finished client streaming call", cc.Target(), method, start, err)
+			return nil, err
+		}
+		s := &clientStream{
+			ClientStream:  cs,
+			serverStreams: desc.ServerStreams,
+			done: func(err error) {
+				grpcClientDone(ctx, logger, "finished client streaming call", cc.Target(), method, start, err)
+			},
+		}
+		s.stop = context.AfterFunc(ctx, func() {
+			s.finish(status.FromContextError(ctx.Err()).Err())
+		})
+		return s, nil
+	}
+}
+
+// clientStream is a grpc.ClientStream calling done with the error ending the
+// stream, nil if it ended normally.
+type clientStream struct {
+	grpc.ClientStream
+	// serverStreams is set if the server sends a stream of messages, the
+	// stream then ending with io.EOF rather than its single message.
+	serverStreams bool
+	once          sync.Once
+	done          func(err error)
+	// stop stops the call of finish once the context is done.
+	stop func() bool
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		// io.EOF leaves the status of the stream to RecvMsg.
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.serverStreams:
+		s.end(nil)
+	}
+	return err
+}
+
+// end finishes the stream ended by one of its methods.
+func (s *clientStream) end(err error) {
+	s.stop()
+	s.finish(err)
+}
+
+// finish calls done once, with the error ending the stream.
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		s.done(err)
+	})
+}
+
+// outgoingRequestID forwards the request id of ctx in the outgoing metadata,
+// under the first request id key of logger.
+func outgoingRequestID(ctx context.Context, logger *zap.Logger) context.Context {
+	var keys []string
+	if s, ok := coreOf(logger); ok {
+		keys = s.requestIDKeys
+	}
+	keys = requestIDKeys(keys)
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && requestIDFromMetadata(md, keys) != "" {
+		return ctx
+	}
+	if id := contextInfoFrom(ctx).requestID(keys); id != "" {
+		return metadata.AppendToOutgoingContext(ctx, keys[0], id)
+	}
+	return ctx
+}
+
+func grpcClientDone(ctx context.Context, logger *zap.Logger, msg, target, method string, start time.Time, err error) {
+	code := status.Code(err)
+	if ce := logger.Check(grpcCodeLevel(code), msg); ce != nil {
+		ce.Write(
+			Context(ctx),
+			zap.String("grpc.target", target),
+			zap.String("grpc.method", method),
+			zap.String("grpc.code", code.String()),
+			SinceMS("grpc.duration", start),
+			If(err != nil, zap.Error(err)),
+		)
+	}
+}
@@ -0,0 +1,131 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// fakeClientStream is a grpc.ClientStream returning the errors set.
+type fakeClientStream struct {
+	grpc.ClientStream
+	sendErr, closeErr, recvErr error
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error { return s.sendErr }
+func (s *fakeClientStream) CloseSend() error            { return s.closeErr }
+func (s *fakeClientStream) RecvMsg(m interface{}) error { return s.recvErr }
+
+func TestStreamClientInterceptor(t *testing.T) {
+	cc, err := grpc.Dial("passthrough:///test", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	errUnavailable := status.Error(codes.Unavailable, "unavailable")
+	tests := []struct {
+		name          string
+		serverStreams bool
+		stream        fakeClientStream
+		// use uses the stream, cancel canceling its context.
+		use      func(s grpc.ClientStream, cancel func())
+		wantCode string // the code logged, none if empty
+	}{
+		{
+			name:          "server stream until io.EOF",
+			serverStreams: true,
+			stream:        fakeClientStream{recvErr: io.EOF},
+			use:           func(s grpc.ClientStream, cancel func()) { s.RecvMsg(nil) },
+			wantCode:      "OK",
+		},
+		{
+			name:          "server stream still open",
+			serverStreams: true,
+			use:           func(s grpc.ClientStream, cancel func()) { s.RecvMsg(nil) },
+		},
+		{
+			name:     "single response",
+			use:      func(s grpc.ClientStream, cancel func()) { s.RecvMsg(nil) },
+			wantCode: "OK",
+		},
+		{
+			name:     "recv error",
+			stream:   fakeClientStream{recvErr: errUnavailable},
+			use:      func(s grpc.ClientStream, cancel func()) { s.RecvMsg(nil) },
+			wantCode: "Unavailable",
+		},
+		{
+			name:     "send error",
+			stream:   fakeClientStream{sendErr: errUnavailable},
+			use:      func(s grpc.ClientStream, cancel func()) { s.SendMsg(nil) },
+			wantCode: "Unavailable",
+		},
+		{
+			name:   "send io.EOF left to recv",
+			stream: fakeClientStream{sendErr: io.EOF},
+			use:    func(s grpc.ClientStream, cancel func()) { s.SendMsg(nil) },
+		},
+		{
+			name:     "close send error",
+			stream:   fakeClientStream{closeErr: errors.New("closed")},
+			use:      func(s grpc.ClientStream, cancel func()) { s.CloseSend() },
+			wantCode: "Unknown",
+		},
+		{
+			name:          "dropped stream canceled",
+			serverStreams: true,
+			use:           func(s grpc.ClientStream, cancel func()) { cancel() },
+			wantCode:      "Canceled",
+		},
+		{
+			name:          "canceled after the end",
+			serverStreams: true,
+			stream:        fakeClientStream{recvErr: io.EOF},
+			use: func(s grpc.ClientStream, cancel func()) {
+				s.RecvMsg(nil)
+				cancel()
+			},
+			wantCode: "OK",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obs, logs := observer.New(zapcore.DebugLevel)
+			logger := Zap(zapcore.DebugLevel, WithOutput(zapcore.AddSync(ioutil.Discard)), WithCores(obs))
+			streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &tt.stream, nil
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			desc := &grpc.StreamDesc{ServerStreams: tt.serverStreams}
+			s, err := StreamClientInterceptor(logger)(ctx, desc, cc, "/test.Service/Method", streamer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.use(s, cancel)
+			if tt.wantCode == "" {
+				if logs.Len() != 0 {
+					t.Fatalf("logged %d entries", logs.Len())
+				}
+				return
+			}
+			eventually(t, func() bool { return logs.Len() != 0 })
+			entries := logs.AllUntimed()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			if got := entries[0].ContextMap()["grpc.code"]; got != tt.wantCode {
+				t.Errorf("grpc.code = %v, want %s", got, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package zapx
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/grpclog"
+)
+
+// grpcLogger is a grpclog.LoggerV2 writing to a zap logger.
+type grpcLogger struct {
+	logger    *zap.Logger
+	verbosity int
+}
+
+// NewGrpcLogger returns a grpclog.LoggerV2 writing to logger, its V method
+// enabling the levels up to verbosity. The entries are not notified, see
+// NoSlack, lest a failing notifier feeds itself through the logs of grpc.
+func NewGrpcLogger(logger *zap.Logger, verbosity int) grpclog.LoggerV2 {
+	return &grpcLogger{
+		// skip log, the method and the grpclog function
+		logger:    logger.WithOptions(zap.AddCallerSkip(3)).With(NoSlack()),
+		verbosity: verbosity,
+	}
+}
+
+// ReplaceGrpcLogger makes grpc, and the failures of zapx itself reported to
+// grpclog, log to logger, see NewGrpcLogger. The verbosity is read from the
+// GRPC_GO_LOG_VERBOSITY_LEVEL environment variable, as grpc does. It must be
+// called before any grpc function, i.e. in an init function.
+func ReplaceGrpcLogger(logger *zap.Logger) {
+	verbosity, _ := strconv.Atoi(os.Getenv("GRPC_GO_LOG_VERBOSITY_LEVEL"))
+	grpclog.SetLoggerV2(NewGrpcLogger(logger, verbosity))
+}
+
+func (l *grpcLogger) log(depth int, level zapcore.Level, msg string) {
+	logger := l.logger
+	if depth != 0 {
+		logger = logger.WithOptions(zap.AddCallerSkip(depth))
+	}
+	if ce := logger.Check(level, msg); ce != nil {
+		ce.Write()
+	}
+}
+
+func sprintln(args []interface{}) string {
+	s := fmt.Sprintln(args...)
+	return s[:len(s)-1]
+}
+
+// Info is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Info(args ...interface{}) {
+	l.log(0, zapcore.InfoLevel, fmt.Sprint(args...))
+}
+
+// Infoln is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Infoln(args ...interface{}) {
+	l.log(0, zapcore.InfoLevel, sprintln(args))
+}
+
+// Infof is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Infof(format string, args ...interface{}) {
+	l.log(0, zapcore.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// Warning is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Warning(args ...interface{}) {
+	l.log(0, zapcore.WarnLevel, fmt.Sprint(args...))
+}
+
+// Warningln is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Warningln(args ...interface{}) {
+	l.log(0, zapcore.WarnLevel, sprintln(args))
+}
+
+// Warningf is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Warningf(format string, args ...interface{}) {
+	l.log(0, zapcore.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+// Error is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Error(args ...interface{}) {
+	l.log(0, zapcore.ErrorLevel, fmt.Sprint(args...))
+}
+
+// Errorln is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Errorln(args ...interface{}) {
+	l.log(0, zapcore.ErrorLevel, sprintln(args))
+}
+
+// Errorf is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Errorf(format string, args ...interface{}) {
+	l.log(0, zapcore.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// Fatal is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Fatal(args ...interface{}) {
+	l.log(0, zapcore.FatalLevel, fmt.Sprint(args...))
+}
+
+// Fatalln is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Fatalln(args ...interface{}) {
+	l.log(0, zapcore.FatalLevel, sprintln(args))
+}
+
+// Fatalf is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) Fatalf(format string, args ...interface{}) {
+	l.log(0, zapcore.FatalLevel, fmt.Sprintf(format, args...))
+}
+
+// V is grpclog.LoggerV2 implementation.
+func (l *grpcLogger) V(level int) bool {
+	return level <= l.verbosity
+}
+
+// InfoDepth is grpclog.DepthLoggerV2 implementation.
+func (l *grpcLogger) InfoDepth(depth int, args ...interface{}) {
+	l.log(depth, zapcore.InfoLevel, sprintln(args))
+}
+
+// WarningDepth is grpclog.DepthLoggerV2 implementation.
+func (l *grpcLogger) WarningDepth(depth int, args ...interface{}) {
+	l.log(depth, zapcore.WarnLevel, sprintln(args))
+}
+
+// ErrorDepth is grpclog.DepthLoggerV2 implementation.
+func (l *grpcLogger) ErrorDepth(depth int, args ...interface{}) {
+	l.log(depth, zapcore.ErrorLevel, sprintln(args))
+}
+
+// FatalDepth is grpclog.DepthLoggerV2 implementation.
+func (l *grpcLogger) FatalDepth(depth int, args ...interface{}) {
+	l.log(depth, zapcore.FatalLevel, sprintln(args))
+}
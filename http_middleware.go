@@ -0,0 +1,145 @@
+package zapx
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// functionExecutionIDHeader is the header of the execution id of the
+	// requests served by Cloud Functions.
+	functionExecutionIDHeader = "Function-Execution-Id"
+	// appEngineRequestLogIDHeader is the header of the id of the request log
+	// of the requests served by App Engine.
+	appEngineRequestLogIDHeader = "X-Appengine-Request-Log-Id"
+)
+
+// HTTPMiddleware returns a middleware logging an httpRequest entry, see
+// Request, with the trace of the request, see Context, for every request
+// served: at error level for 5xx responses, at warn level for 4xx and at info
+// level otherwise. The handlers find a logger carrying the trace, request id
+// and user of the request in the context, see ForRequest and
+// LoggerFromContext. The trace is taken from the headers of the request if
+// the context has none, e.g. x-cloud-trace-context set by the load balancer.
+// On Cloud Functions, the execution id of the request is attached as the
+// "execution_id" label, and on App Engine, the id of the request log as the
+// "appengine.googleapis.com/request_id" label, as the runtimes do for their
+// own entries, so that the entries are nested under the request log.
+func HTTPMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := WrapResponseWriter(w)
+			r, reqLogger := ForHTTPRequest(logger, r)
+			next.ServeHTTP(rw, r)
+
+			level := zapcore.InfoLevel
+			switch status := rw.Status(); {
+			case status >= 500:
+				level = zapcore.ErrorLevel
+			case status >= 400:
+				level = zapcore.WarnLevel
+			}
+			if ce := reqLogger.Check(level, r.Method+" "+r.URL.Path); ce != nil {
+				ce.Write(Request(rw.Entry(r)))
+			}
+		})
+	}
+}
+
+// ForHTTPRequest is ForRequest for r, the trace and the request id missing
+// from the context of r being taken from its headers, see HTTPContext. It
+// returns the logger and r with the logger in its context, for the
+// middlewares of other frameworks, see HTTPMiddleware.
+func ForHTTPRequest(logger *zap.Logger, r *http.Request) (*http.Request, *zap.Logger) {
+	ctx := contextWithRequestMetadata(r.Context(), HTTPRequestEntry{Request: r})
+	reqLogger := ForRequest(logger, ctx, requestFields(r)...)
+	return r.WithContext(ContextWithLogger(ctx, reqLogger)), reqLogger
+}
+
+// RecoverMiddleware returns a middleware recovering the panics of the
+// handlers, logged like Recover with the request and the trace of the
+// request, with the logger found in the context of the request if any, e.g.
+// set by HTTPMiddleware. A 500 response is sent unless the handler wrote the
+// response already, or the panic is propagated if repanic is set, e.g. for
+// the server to abort the response. http.ErrAbortHandler is propagated
+// without being logged.
+func RecoverMiddleware(logger *zap.Logger, repanic bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := WrapResponseWriter(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				reqLogger, ok := r.Context().Value(loggerContextKey{}).(*zap.Logger)
+				if !ok || reqLogger == nil {
+					reqLogger = ForRequest(logger, r.Context(), requestFields(r)...)
+				}
+				entry := rw.Entry(r)
+				if rw.status == 0 {
+					entry.Status = http.StatusInternalServerError
+				}
+				logPanic(reqLogger, v, !repanic, Request(entry))
+				if repanic {
+					panic(v)
+				}
+				if rw.status == 0 {
+					http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// HTTPContext constructs a field that carries the trace and the request id of
+// r, like Context, looked up from the context of r, then from the trace
+// propagation headers of r, e.g. x-cloud-trace-context or traceparent, and
+// the request id headers, see WithRequestIDKeys.
+func HTTPContext(r *http.Request) zapcore.Field {
+	info, _ := httpContextInfo(r)
+	return zap.Reflect(logKeyContextInfo, info)
+}
+
+// httpContextInfo returns the contextInfo of the context of r, completed
+// with the headers of r, and whether the headers completed it.
+func httpContextInfo(r *http.Request) (contextInfo, bool) {
+	info := contextInfoFrom(r.Context())
+	if info.TraceID != "" && info.RequestID != "" {
+		return info, false
+	}
+	md := make(metadata.MD, len(r.Header))
+	for key, vals := range r.Header {
+		md[strings.ToLower(key)] = vals
+	}
+	found := info.TraceID == "" && traceFromMetadata(md, &info)
+	if info.RequestID == "" && info.md == nil {
+		// looked up by the core, see WithRequestIDKeys.
+		info.md, found = md, true
+	}
+	return info, found
+}
+
+// requestFields returns the fields of r that ForRequest does not find in the
+// context of r.
+func requestFields(r *http.Request) []zapcore.Field {
+	var fs []zapcore.Field
+	if info, found := httpContextInfo(r); found {
+		fs = append(fs, zap.Reflect(logKeyContextInfo, info))
+	}
+	if id := r.Header.Get(functionExecutionIDHeader); id != "" {
+		fs = append(fs, Label("execution_id", id))
+	}
+	if id := r.Header.Get(appEngineRequestLogIDHeader); id != "" {
+		fs = append(fs, Label("appengine.googleapis.com/request_id", id))
+	}
+	return fs
+}
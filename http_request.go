@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -25,6 +26,19 @@ type HTTPRequestEntry struct {
 	RemoteIP      string
 	Referer       string
 	Latency       time.Duration
+	// Protocol is the protocol of the request, e.g. "HTTP/1.1", the one of
+	// Request by default.
+	Protocol string
+	// ServerIP is the IP address, and optionally the port, of the server
+	// the request was sent to.
+	ServerIP string
+	// CacheLookup, CacheHit, CacheValidatedWithOriginServer and
+	// CacheFillBytes describe how a cache in front of the service, e.g. a
+	// CDN, served the request.
+	CacheLookup                    bool
+	CacheHit                       bool
+	CacheValidatedWithOriginServer bool
+	CacheFillBytes                 int64
 }
 
 func (e *HTTPRequestEntry) method() string {
@@ -59,6 +73,16 @@ func (e *HTTPRequestEntry) url() string {
 	return uri
 }
 
+func (e *HTTPRequestEntry) protocol() string {
+	if e.Protocol != "" {
+		return e.Protocol
+	}
+	if e.Request == nil {
+		return ""
+	}
+	return e.Request.Proto
+}
+
 func (e *HTTPRequestEntry) userAgent() string {
 	if e.UserAgent != "" {
 		return e.UserAgent
@@ -104,10 +128,11 @@ func (e *HTTPRequestEntry) remoteIP() string {
 
 // MarshalLogObject is ObjectMarshaler implementation.
 func (t HTTPRequestEntry) MarshalLogObject(e zapcore.ObjectEncoder) error {
-	if t.RequestMethod == "" {
-		t.RequestMethod = "POST"
+	method := t.method()
+	if method == "" {
+		method = "POST"
 	}
-	e.AddString("requestMethod", t.method())
+	e.AddString("requestMethod", method)
 	addNonEmpty(e, "requestUrl", t.url())
 	addNonEmpty(e, "requestSize", strconv.FormatInt(t.RequestSize, 10))
 	if t.Status != 0 {
@@ -120,6 +145,20 @@ func (t HTTPRequestEntry) MarshalLogObject(e zapcore.ObjectEncoder) error {
 	if t.Latency != 0 {
 		e.AddString("latency", fmt.Sprintf("%fs", t.Latency.Seconds()))
 	}
+	addNonEmpty(e, "protocol", t.protocol())
+	addNonEmpty(e, "serverIp", t.ServerIP)
+	if t.CacheLookup {
+		e.AddBool("cacheLookup", true)
+	}
+	if t.CacheHit {
+		e.AddBool("cacheHit", true)
+	}
+	if t.CacheValidatedWithOriginServer {
+		e.AddBool("cacheValidatedWithOriginServer", true)
+	}
+	if t.CacheFillBytes != 0 {
+		e.AddString("cacheFillBytes", strconv.FormatInt(t.CacheFillBytes, 10))
+	}
 	return nil
 }
 
@@ -128,3 +167,106 @@ func addNonEmpty(e zapcore.ObjectEncoder, key, val string) {
 		e.AddString(key, val)
 	}
 }
+
+// deniedHeaders are the request headers always masked when logged, see
+// WithRequestHeaders.
+var deniedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// requestRedaction masks the query parameters of the requests logged, and
+// selects the headers logged.
+type requestRedaction struct {
+	query   map[string]bool
+	headers []string
+}
+
+func newRequestRedaction(query, headers []string) *requestRedaction {
+	if len(query) == 0 && len(headers) == 0 {
+		return nil
+	}
+	r := &requestRedaction{query: make(map[string]bool, len(query)), headers: headers}
+	for _, key := range query {
+		r.query[strings.ToLower(key)] = true
+	}
+	return r
+}
+
+// rawQuery returns the query q with the values of the redacted parameters
+// masked, keeping the order of the parameters.
+func (r *requestRedaction) rawQuery(q string) string {
+	if len(r.query) == 0 || q == "" {
+		return q
+	}
+	params := strings.Split(q, "&")
+	for i, p := range params {
+		rawKey := p
+		if eq := strings.IndexByte(p, '='); eq != -1 {
+			rawKey = p[:eq]
+		}
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if r.query[strings.ToLower(key)] {
+			params[i] = rawKey + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// url returns the url u with its query redacted.
+func (r *requestRedaction) url(u string) string {
+	i := strings.IndexByte(u, '?')
+	if i == -1 {
+		return u
+	}
+	return u[:i+1] + r.rawQuery(u[i+1:])
+}
+
+// request returns a shallow copy of req with its query redacted.
+func (r *requestRedaction) request(req *http.Request) *http.Request {
+	if req == nil || req.URL == nil || len(r.query) == 0 {
+		return req
+	}
+	c := *req
+	u := *req.URL
+	u.RawQuery = r.rawQuery(u.RawQuery)
+	c.URL = &u
+	c.RequestURI = r.url(req.RequestURI)
+	return &c
+}
+
+// entry returns e with its url redacted.
+func (r *requestRedaction) entry(e HTTPRequestEntry) HTTPRequestEntry {
+	e.RequestURL = r.url(e.url())
+	e.Referer = r.url(e.referer())
+	return e
+}
+
+// headerObject returns the headers logged of h, nil if none.
+func (r *requestRedaction) headerObject(h http.Header) zapcore.ObjectMarshaler {
+	if len(r.headers) == 0 || h == nil {
+		return nil
+	}
+	m := make(stringMap, len(r.headers))
+	for _, name := range r.headers {
+		vals := h.Values(name)
+		if len(vals) == 0 {
+			continue
+		}
+		name = http.CanonicalHeaderKey(name)
+		if deniedHeaders[name] {
+			m[name] = redactedValue
+		} else {
+			m[name] = strings.Join(vals, ", ")
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,33 @@
+package zapx
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// internalErrorHandler is the default handler of the failures of zapx itself,
+// see WithInternalErrorHandler.
+var internalErrorHandler = func(err error) {
+	grpclog.Error(err)
+}
+
+// errorHandler receives the failures of zapx itself, e.g. the notifications
+// that could not be delivered. The nil handler is internalErrorHandler.
+type errorHandler func(error)
+
+// errorf reports a failure of zapx itself.
+func (h errorHandler) errorf(format string, args ...interface{}) {
+	if h == nil {
+		h = internalErrorHandler
+	}
+	h(fmt.Errorf(format, args...))
+}
+
+// handle reports err, a failure of zapx itself.
+func (h errorHandler) handle(err error) {
+	if h == nil {
+		h = internalErrorHandler
+	}
+	h(err)
+}
@@ -0,0 +1,148 @@
+package zapx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// journaldSocket is the socket of the native protocol of journald.
+	journaldSocket = "/run/systemd/journal/socket"
+	// journaldWriteBuffer is the send buffer of the socket, the entries
+	// being sent as single datagrams.
+	journaldWriteBuffer = 8 << 20
+)
+
+// journaldSink is a zapcore.WriteSyncer sending the entries encoded by the
+// core to journald over its native protocol, with the severity as PRIORITY,
+// the message as MESSAGE and the trace, request id and service context as
+// fields, the entry being attached as is under ENTRY.
+type journaldSink struct {
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+	identifier string
+	svcCtx     ServiceContext
+}
+
+func newJournaldSink(identifier string, svcCtx ServiceContext) (*journaldSink, error) {
+	if _, err := os.Stat(journaldSocket); err != nil {
+		return nil, err
+	}
+	addr := &net.UnixAddr{Name: journaldSocket, Net: "unixgram"}
+	// an unnamed socket, bound automatically
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	// fails without CAP_NET_ADMIN beyond the system maximum, not fatal.
+	conn.SetWriteBuffer(journaldWriteBuffer)
+	if identifier == "" {
+		identifier = svcCtx.Service
+	}
+	return &journaldSink{conn: conn, addr: addr, identifier: identifier, svcCtx: svcCtx}, nil
+}
+
+// Write sends the entry encoded in p.
+func (s *journaldSink) Write(p []byte) (int, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(p, &m); err != nil {
+		return 0, err
+	}
+	str := func(key string) string {
+		var v string
+		json.Unmarshal(m[key], &v)
+		return v
+	}
+	severity, ok := syslogSeverities[str(StackdriverEncoderConfig.LevelKey)]
+	if !ok {
+		severity = syslogSeverities["INFO"]
+	}
+	var b bytes.Buffer
+	journaldField(&b, "MESSAGE", str(StackdriverEncoderConfig.MessageKey))
+	journaldField(&b, "PRIORITY", strconv.Itoa(severity))
+	journaldField(&b, "SYSLOG_IDENTIFIER", s.identifier)
+	journaldField(&b, "SERVICE", s.svcCtx.Service)
+	journaldField(&b, "VERSION", s.svcCtx.Version)
+	if trace := str("logging.googleapis.com/trace"); trace != "" {
+		journaldField(&b, "TRACE_ID", lastSegment(trace))
+	}
+	if span := str("logging.googleapis.com/spanId"); span != "" {
+		journaldField(&b, "SPAN_ID", span)
+	}
+	if id := str("request_id"); id != "" {
+		journaldField(&b, "REQUEST_ID", id)
+	}
+	var loc struct {
+		File     string `json:"file"`
+		Line     int64  `json:"line"`
+		Function string `json:"function"`
+	}
+	if json.Unmarshal(m["logging.googleapis.com/sourceLocation"], &loc) == nil && loc.Function != "" {
+		if loc.File != "" {
+			line := strconv.FormatInt(loc.Line, 10)
+			// zap's trimmed path ends with the line
+			journaldField(&b, "CODE_FILE", strings.TrimSuffix(loc.File, ":"+line))
+			journaldField(&b, "CODE_LINE", line)
+		}
+		journaldField(&b, "CODE_FUNC", loc.Function)
+	}
+	var lbs map[string]string
+	if json.Unmarshal(m["logging.googleapis.com/labels"], &lbs) == nil {
+		for key, val := range lbs {
+			journaldField(&b, "LABEL_"+journaldKey(key), val)
+		}
+	}
+	journaldField(&b, "ENTRY", string(bytes.TrimRight(p, "\n")))
+	if _, err := s.conn.WriteToUnix(b.Bytes(), s.addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sync is a no-op, the entries are not buffered.
+func (s *journaldSink) Sync() error {
+	return nil
+}
+
+// Close closes the socket.
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
+
+// journaldField appends the field key to b, with the binary framing of the
+// native protocol if val spans several lines.
+func journaldField(b *bytes.Buffer, key, val string) {
+	b.WriteString(key)
+	if !strings.Contains(val, "\n") {
+		b.WriteByte('=')
+		b.WriteString(val)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(val)))
+	b.Write(size[:])
+	b.WriteString(val)
+	b.WriteByte('\n')
+}
+
+// journaldKey returns key as a journal field name: upper case letters, digits
+// and underscores.
+func journaldKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return unicode.ToUpper(r)
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+}
@@ -0,0 +1,51 @@
+package zapx
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	logKeyK8sObject        = "zapx.k8s_object"
+	logKeyK8sObjectPayload = "k8sObject"
+)
+
+// k8sObject is a reference to a Kubernetes object.
+type k8sObject struct {
+	kind, namespace, name, uid string
+}
+
+// K8sObject constructs a field that references the Kubernetes object kind
+// namespace/name, e.g. the object reconciled by a controller. The entry
+// carries the reference under "k8sObject" and the kind, namespace and name as
+// the "k8s.kind", "k8s.namespace" and "k8s.name" labels. The namespace is
+// empty for the cluster-scoped objects. The uid, if any, is logged but not
+// labeled.
+func K8sObject(kind, namespace, name string, uid ...string) zapcore.Field {
+	obj := k8sObject{kind: kind, namespace: namespace, name: name}
+	if len(uid) != 0 {
+		obj.uid = uid[0]
+	}
+	return zap.Object(logKeyK8sObject, obj)
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (o k8sObject) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("kind", o.kind)
+	if o.namespace != "" {
+		e.AddString("namespace", o.namespace)
+	}
+	e.AddString("name", o.name)
+	if o.uid != "" {
+		e.AddString("uid", o.uid)
+	}
+	return nil
+}
+
+func (o k8sObject) labels() []zapcore.Field {
+	fs := []zapcore.Field{zap.String("k8s.kind", o.kind)}
+	if o.namespace != "" {
+		fs = append(fs, zap.String("k8s.namespace", o.namespace))
+	}
+	return append(fs, zap.String("k8s.name", o.name))
+}
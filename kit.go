@@ -0,0 +1,70 @@
+package zapx
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// KitLogger implements the Logger interface of go-kit on top of a zap logger,
+// see NewKitLogger.
+type KitLogger struct {
+	logger *zap.Logger
+}
+
+// NewKitLogger returns a go-kit logger writing to logger. The "level" keyval
+// sets the level of the entry, info by default, the "msg" keyval its
+// message, and the other keyvals are logged as fields.
+func NewKitLogger(logger *zap.Logger) KitLogger {
+	return KitLogger{logger: logger.WithOptions(zap.AddCallerSkip(1))}
+}
+
+// Log implements the Logger interface of go-kit.
+func (l KitLogger) Log(keyvals ...interface{}) error {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "(MISSING)")
+	}
+	level := zapcore.InfoLevel
+	var msg string
+	fields := make([]zapcore.Field, 0, len(keyvals)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, val := fmt.Sprint(keyvals[i]), keyvals[i+1]
+		switch key {
+		case "level":
+			level = kitLevel(fmt.Sprint(val))
+			continue
+		case "msg":
+			if s, ok := val.(string); ok {
+				msg = s
+				continue
+			}
+		}
+		switch v := val.(type) {
+		case zapcore.Field:
+			fields = append(fields, v)
+		case error:
+			fields = append(fields, zap.NamedError(key, v))
+		default:
+			fields = append(fields, zap.Any(key, v))
+		}
+	}
+	if ce := l.logger.Check(level, msg); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// kitLevel returns the zap level of a go-kit level.
+func kitLevel(level string) zapcore.Level {
+	switch level {
+	case "debug":
+		return zapcore.DebugLevel
+	case "warn":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
@@ -0,0 +1,73 @@
+package zapx
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Matcher tests an entry, fields being the fields of the entry as written by
+// the core.
+type Matcher func(ent zapcore.Entry, fields []zapcore.Field) bool
+
+// MatchLevel matches the entries at level or above.
+func MatchLevel(level zapcore.Level) Matcher {
+	return func(ent zapcore.Entry, _ []zapcore.Field) bool {
+		return ent.Level >= level
+	}
+}
+
+// MatchLogger matches the entries of the logger name, see zap.Logger.Named,
+// or of its children.
+func MatchLogger(name string) Matcher {
+	return func(ent zapcore.Entry, _ []zapcore.Field) bool {
+		return ent.LoggerName == name || strings.HasPrefix(ent.LoggerName, name+".")
+	}
+}
+
+// MatchAll matches the entries matching all of matchers, e.g. the error
+// entries of a team.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(ent zapcore.Entry, fields []zapcore.Field) bool {
+		for _, match := range matchers {
+			if !match(ent, fields) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchLabel matches the entries carrying the label key with value val.
+func MatchLabel(key, val string) Matcher {
+	return func(_ zapcore.Entry, fields []zapcore.Field) bool {
+		for _, f := range fields {
+			if f.Key != "logging.googleapis.com/labels" {
+				continue
+			}
+			lbs, ok := f.Interface.(labels)
+			if !ok {
+				continue
+			}
+			for _, l := range lbs {
+				if l.Key == key && l.String == val {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// MatchField matches the entries carrying the string field key with value
+// val.
+func MatchField(key, val string) Matcher {
+	return func(_ zapcore.Entry, fields []zapcore.Field) bool {
+		for _, f := range fields {
+			if f.Key == key && f.Type == zapcore.StringType && f.String == val {
+				return true
+			}
+		}
+		return false
+	}
+}
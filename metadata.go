@@ -0,0 +1,64 @@
+package zapx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// metadataTimeout bounds each query to the GCE metadata server, which is not
+// reachable outside of GCP.
+const metadataTimeout = time.Second
+
+// metadataClient queries the GCE metadata server.
+type metadataClient struct {
+	host   string
+	client *http.Client
+	// unreachable is set after the first connection failure, so that the
+	// detection does not wait for every query outside of GCP.
+	unreachable bool
+}
+
+func newMetadataClient() *metadataClient {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	return &metadataClient{host: host, client: &http.Client{Timeout: metadataTimeout}}
+}
+
+// get returns the value of the metadata path, e.g. "project/project-id", or
+// an empty string if it is not available.
+func (c *metadataClient) get(ctx context.Context, path string) string {
+	if c.unreachable {
+		return ""
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+c.host+"/computeMetadata/v1/"+path, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.unreachable = true
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// lastSegment returns the last segment of a metadata value such as
+// "projects/123/zones/us-central1-a".
+func lastSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
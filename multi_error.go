@@ -0,0 +1,45 @@
+package zapx
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// splitErrors returns the errors joined in err, by errors.Join or multierr,
+// or nil if err is not a joined error.
+func splitErrors(err error) []error {
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		return e.Unwrap()
+	case interface{ Errors() []error }:
+		return e.Errors()
+	}
+	return nil
+}
+
+// multiError renders a joined error as its message and the array of the
+// errors joined, each parsed by the error parser if any, see
+// WithErrorParser.
+type multiError struct {
+	err   error
+	errs  []error
+	parse func(error) (zapcore.ObjectMarshaler, bool)
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (m multiError) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("message", m.err.Error())
+	return e.AddArray("errors", zapcore.ArrayMarshalerFunc(func(ae zapcore.ArrayEncoder) error {
+		for _, err := range m.errs {
+			if m.parse != nil {
+				if obj, ok := m.parse(err); ok {
+					if err := ae.AppendObject(obj); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			ae.AppendString(err.Error())
+		}
+		return nil
+	}))
+}
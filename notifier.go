@@ -0,0 +1,267 @@
+package zapx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lixin9311/backoff/v2"
+	"go.uber.org/zap/zapcore"
+)
+
+// Notifier delivers the entries marked for notification, see Slack, to an
+// alerting backend. fields are the fields of the entry as written by the
+// core. Notify is retried with backoff while it fails, unless the error
+// implements Retryable() bool and reports false.
+type Notifier interface {
+	Notify(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error
+
+// Notify is Notifier implementation.
+func (f NotifierFunc) Notify(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+	return f(ctx, ent, fields)
+}
+
+// notifyTargets returns the notifiers of an entry of the given level. The
+// slack url of the entry, if any, overrides the notifiers of the logger.
+func (s *stackdriver) notifyTargets(level zapcore.Level, slackURL string) []Notifier {
+	if slackURL != "" {
+		return []Notifier{s.slackTarget(slackURL)}
+	}
+	dest := s.slackLevelURL(level)
+	if dest == "" {
+		return s.notifiers
+	}
+	targets := make([]Notifier, 0, len(s.notifiers)+1)
+	targets = append(targets, s.slackTarget(dest))
+	return append(targets, s.notifiers...)
+}
+
+// notifyRoute notifies the entries matching, see WithNotificationRoute, with
+// notifier, or else to the slack destination slackURL.
+type notifyRoute struct {
+	match    Matcher
+	notifier Notifier
+	slackURL string
+}
+
+// entryTargets returns the notifiers of an entry: the destinations of the
+// routes matching it, or else the notifiers of its level. The slack url of
+// the entry, if any, overrides both.
+func (s *stackdriver) entryTargets(ent zapcore.Entry, fields []zapcore.Field, slackURL string) []Notifier {
+	if slackURL == "" {
+		var targets []Notifier
+		for _, r := range s.notifyRoutes {
+			if !r.match(ent, fields) {
+				continue
+			}
+			if r.notifier != nil {
+				targets = append(targets, r.notifier)
+			} else {
+				targets = append(targets, s.slackTarget(r.slackURL))
+			}
+		}
+		if len(targets) != 0 {
+			return targets
+		}
+	}
+	return s.notifyTargets(ent.Level, slackURL)
+}
+
+// slackLevelURL returns the default slack destination of the entries of the
+// given level: the one of the highest level of WithSlackURLs not above it, or
+// the slack url.
+func (s *stackdriver) slackLevelURL(level zapcore.Level) string {
+	dest, found := s.slackURL, false
+	var best zapcore.Level
+	for l, url := range s.slackLevels {
+		if l <= level && (!found || l > best) {
+			dest, best, found = url, l, true
+		}
+	}
+	return dest
+}
+
+// slackTarget returns the notifier of the slack webhook url.
+func (s *stackdriver) slackTarget(url string) Notifier {
+	if s.slackDigest != nil {
+		return slackDigestTarget{d: s.slackDigest, url: url}
+	}
+	return s.slackNotifier(url)
+}
+
+func (s *stackdriver) postNotification(ctx context.Context, targets []Notifier, ent zapcore.Entry, fields []zapcore.Field) {
+	for _, n := range targets {
+		b := s.breakers.of(n)
+		if !b.allow(s.clock.Now()) {
+			s.stats.NotificationDelivered(notifierName(n), 0, 0, errCircuitOpen)
+			s.deadLetter(n, ent, fields, errCircuitOpen)
+			continue
+		}
+		var attempts int
+		notify := func(ctx context.Context) error {
+			attempts++
+			return n.Notify(ctx, ent, fields)
+		}
+		start := s.clock.Now()
+		err := backoff.Invoke(ctx, notify, s.retrier.Retry)
+		s.stats.NotificationDelivered(notifierName(n), attempts, s.clock.Now().Sub(start), err)
+		if err != nil {
+			s.onError.errorf("zapx: failed to post notification after %d attempts: %w", attempts, err)
+			s.deadLetter(n, ent, fields, err)
+		}
+		b.report(err, s.clock.Now())
+	}
+}
+
+// OverflowPolicy decides what happens to a notification when the queue of
+// the notification workers is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest drops the oldest queued notification.
+	DropOldest OverflowPolicy = iota
+	// Block blocks the logging call until the notification is queued.
+	Block
+)
+
+type notifyJob struct {
+	targets []Notifier
+	ent     zapcore.Entry
+	fields  []zapcore.Field
+}
+
+// syncTimeout bounds the wait of Sync for the pending notifications.
+const syncTimeout = 15 * time.Second
+
+// notifyPool delivers the notifications of a logger and all of its children
+// with a bounded number of workers, so that an error storm neither spawns a
+// goroutine per entry nor hammers the backends.
+type notifyPool struct {
+	s       *stackdriver
+	workers int
+	policy  OverflowPolicy
+	queue   chan notifyJob
+	once    sync.Once
+	// inflight counts the queued and running jobs, idle being closed when it
+	// drops to zero, for Sync.
+	idleMu   sync.Mutex
+	inflight int
+	idle     chan struct{}
+	// syncTimeout bounds the wait of Sync, none if negative.
+	syncTimeout time.Duration
+	// mu guards the queue against its closing by stop.
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func newNotifyPool(s *stackdriver, workers, depth int, policy OverflowPolicy) *notifyPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	return &notifyPool{s: s, workers: workers, policy: policy, queue: make(chan notifyJob, depth), syncTimeout: syncTimeout}
+}
+
+func (p *notifyPool) enqueue(job notifyJob) {
+	p.once.Do(func() {
+		for i := 0; i < p.workers; i++ {
+			go p.work()
+		}
+	})
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		p.s.onError.errorf("zapx: logger closed, dropping notification %q", job.ent.Message)
+		p.s.stats.NotificationDropped(DropClosed)
+		return
+	}
+	p.add(1)
+	if p.policy == Block {
+		p.queue <- job
+		return
+	}
+	for {
+		select {
+		case p.queue <- job:
+			return
+		default:
+		}
+		select {
+		case old := <-p.queue:
+			p.s.onError.errorf("zapx: notification queue full, dropping notification %q", old.ent.Message)
+			p.s.stats.NotificationDropped(DropQueueFull)
+			p.add(-1)
+		default:
+		}
+	}
+}
+
+func (p *notifyPool) add(n int) {
+	p.idleMu.Lock()
+	defer p.idleMu.Unlock()
+	p.inflight += n
+	if p.inflight == 0 && p.idle != nil {
+		close(p.idle)
+		p.idle = nil
+	}
+}
+
+// drain waits for the queued and running jobs until ctx is done.
+func (p *notifyPool) drain(ctx context.Context) error {
+	p.idleMu.Lock()
+	if p.inflight == 0 {
+		p.idleMu.Unlock()
+		return nil
+	}
+	if p.idle == nil {
+		p.idle = make(chan struct{})
+	}
+	idle := p.idle
+	p.idleMu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		p.idleMu.Lock()
+		n := p.inflight
+		p.idleMu.Unlock()
+		return fmt.Errorf("zapx: %d pending notifications abandoned: %w", n, ctx.Err())
+	}
+}
+
+// stop stops the workers once the queued jobs are done, the jobs enqueued
+// afterwards being dropped.
+func (p *notifyPool) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+}
+
+func (p *notifyPool) work() {
+	for job := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), p.s.notifyTimeout)
+		p.s.postNotification(ctx, job.targets, job.ent, job.fields)
+		cancel()
+		p.add(-1)
+	}
+}
+
+// deadLetter hands a notification that could not be delivered to the dead
+// letter sink, if any.
+func (s *stackdriver) deadLetter(n Notifier, ent zapcore.Entry, fields []zapcore.Field, err error) {
+	if s.deadLetters != nil {
+		dl := newDeadLetter(n, ent, fields, err)
+		dl.onError = s.onError
+		s.deadLetters(dl)
+	}
+}
@@ -0,0 +1,97 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNotifyPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		depth   int
+		policy  OverflowPolicy
+		// dropping reports whether notifications may be dropped.
+		dropping bool
+	}{
+		{"single worker blocking", 1, 1, Block, false},
+		{"several workers blocking", 4, 16, Block, false},
+		{"dropping the oldest", 2, 2, DropOldest, true},
+	}
+	const loggers, entries = 8, 50
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var notified int64
+			n := NotifierFunc(func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+				atomic.AddInt64(&notified, 1)
+				return nil
+			})
+			var dropped int64
+			logger := Zap(zapcore.DebugLevel,
+				WithOutput(zapcore.AddSync(ioutil.Discard)),
+				WithNotifier(n),
+				WithNotificationWorkers(tt.workers, tt.depth, tt.policy),
+				WithSyncTimeout(-1),
+				WithInternalErrorHandler(func(error) { atomic.AddInt64(&dropped, 1) }),
+			)
+			// enqueue and Sync concurrently, every Sync returning once idle.
+			var wg sync.WaitGroup
+			for i := 0; i < loggers; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < entries; j++ {
+						logger.Error("boom", Slack())
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					for j := 0; j < entries; j++ {
+						if err := logger.Sync(); err != nil {
+							t.Error(err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if err := logger.Sync(); err != nil {
+				t.Fatal(err)
+			}
+			got := atomic.LoadInt64(&notified) + atomic.LoadInt64(&dropped)
+			if got != loggers*entries {
+				t.Errorf("notified %d, dropped %d, want %d in total", notified, dropped, loggers*entries)
+			}
+			if !tt.dropping && dropped != 0 {
+				t.Errorf("dropped %d notifications", dropped)
+			}
+		})
+	}
+}
+
+func TestNotifyPoolDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	n := NotifierFunc(func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+		<-release
+		return nil
+	})
+	logger := Zap(zapcore.DebugLevel,
+		WithOutput(zapcore.AddSync(ioutil.Discard)),
+		WithNotifier(n),
+		WithSyncTimeout(10*time.Millisecond),
+	)
+	logger.Error("boom", Slack())
+	err := logger.Sync()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Sync() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+	eventually(t, func() bool { return logger.Sync() == nil })
+}
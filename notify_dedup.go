@@ -0,0 +1,94 @@
+package zapx
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// notifyDeduper collapses the notifications sharing a fingerprint, i.e. the
+// message, caller and error, within a window: the first one is sent right
+// away, and the following ones are counted and sent as one notification
+// carrying the number of occurrences when the window ends. It is shared by a
+// logger and all of its children.
+type notifyDeduper struct {
+	window time.Duration
+	send   func(notifyJob)
+
+	mu    sync.Mutex
+	sites map[string]*notifyDedupState
+}
+
+type notifyDedupState struct {
+	// count is the number of notifications collapsed after the first one.
+	count int64
+	last  notifyJob
+	timer *time.Timer
+}
+
+func newNotifyDeduper(window time.Duration, send func(notifyJob)) *notifyDeduper {
+	return &notifyDeduper{window: window, send: send, sites: make(map[string]*notifyDedupState)}
+}
+
+func notifyFingerprint(ent zapcore.Entry, fields []zapcore.Field) string {
+	key := ent.Caller.String() + "|" + ent.Message
+	for _, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		if err, ok := f.Interface.(error); ok {
+			key += "|" + err.Error()
+		}
+	}
+	return key
+}
+
+// observe reports whether job should be sent now.
+func (d *notifyDeduper) observe(job notifyJob) bool {
+	if d == nil {
+		return true
+	}
+	key := notifyFingerprint(job.ent, job.fields)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if st, ok := d.sites[key]; ok {
+		st.count++
+		st.last = job
+		return false
+	}
+	d.sites[key] = &notifyDedupState{timer: time.AfterFunc(d.window, func() { d.release(key) })}
+	return true
+}
+
+// release ends the window of key, sending the collapsed notifications.
+func (d *notifyDeduper) release(key string) {
+	d.mu.Lock()
+	st, ok := d.sites[key]
+	delete(d.sites, key)
+	d.mu.Unlock()
+	if !ok || st.count == 0 {
+		return
+	}
+	job := st.last
+	job.fields = append(job.fields[:len(job.fields):len(job.fields)], zap.Int64("occurrences", st.count+1))
+	d.send(job)
+}
+
+// flush ends all the windows, e.g. before Sync.
+func (d *notifyDeduper) flush() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	keys := make([]string, 0, len(d.sites))
+	for key, st := range d.sites {
+		st.timer.Stop()
+		keys = append(keys, key)
+	}
+	d.mu.Unlock()
+	for _, key := range keys {
+		d.release(key)
+	}
+}
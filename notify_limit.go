@@ -0,0 +1,97 @@
+package zapx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// notifyLimitWindow is the interval of the rate of notifications, and of the
+// summaries of the notifications suppressed.
+const notifyLimitWindow = time.Minute
+
+// notifyLimiter is a token bucket limiting the notifications of a logger and
+// all of its children. The notifications dropped are counted, and reported
+// by a single summary notification per window.
+type notifyLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+	clock zapcore.Clock
+	// report delivers the summary of n suppressed notifications.
+	report func(n int64)
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	dropped int64
+	// stop stops the summary pending, nil if none.
+	stop func()
+}
+
+func newNotifyLimiter(perWindow, burst int, clock zapcore.Clock, report func(n int64)) *notifyLimiter {
+	if burst <= 0 {
+		burst = perWindow
+	}
+	return &notifyLimiter{
+		rate:   float64(perWindow) / notifyLimitWindow.Seconds(),
+		burst:  float64(burst),
+		clock:  clock,
+		report: report,
+		tokens: float64(burst),
+	}
+}
+
+// allow reports whether a notification may be sent at now.
+func (l *notifyLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+	l.dropped++
+	if l.stop == nil {
+		l.stop = afterFunc(l.clock, notifyLimitWindow, l.flush)
+	}
+	return false
+}
+
+func (l *notifyLimiter) flush() {
+	l.mu.Lock()
+	n := l.dropped
+	l.dropped = 0
+	l.stop = nil
+	l.mu.Unlock()
+	if n > 0 {
+		l.report(n)
+	}
+}
+
+// notifySuppressed notifies the default notifiers that n notifications were
+// suppressed by the rate limit.
+func (s *stackdriver) notifySuppressed(n int64) {
+	targets := s.notifyTargets(zapcore.WarnLevel, "")
+	if len(targets) == 0 {
+		return
+	}
+	ent := zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    s.clock.Now(),
+		Message: fmt.Sprintf("zapx: suppressed %d notifications in the last %s", n, notifyLimitWindow),
+	}
+	fields := []zapcore.Field{zap.Int64("suppressed", n), zap.Object("serviceContext", s.svcCtx)}
+	s.notifyPool.enqueue(notifyJob{targets: targets, ent: ent, fields: fields})
+}
@@ -0,0 +1,105 @@
+package zapx
+
+import (
+	"context"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNotificationRate(t *testing.T) {
+	tests := []struct {
+		name             string
+		perMinute, burst int
+		// log writes the entries, advancing clock.
+		log func(logger *zap.Logger, clock *fakeClock)
+		// want are the messages notified, the summary included, sorted.
+		want []string
+	}{
+		{
+			name:      "burst",
+			perMinute: 2,
+			log: func(logger *zap.Logger, clock *fakeClock) {
+				for i := 0; i < 5; i++ {
+					logger.Error("boom")
+				}
+			},
+			want: []string{"boom", "boom", "zapx: suppressed 3 notifications in the last 1m0s"},
+		},
+		{
+			name:      "refilled",
+			perMinute: 60,
+			burst:     1,
+			log: func(logger *zap.Logger, clock *fakeClock) {
+				logger.Error("first")
+				logger.Error("dropped")
+				clock.Add(time.Second)
+				logger.Error("second")
+			},
+			want: []string{"first", "second", "zapx: suppressed 1 notifications in the last 1m0s"},
+		},
+		{
+			name:      "panics always notified",
+			perMinute: 1,
+			log: func(logger *zap.Logger, clock *fakeClock) {
+				logger.Error("first")
+				func() {
+					defer func() { recover() }()
+					logger.Panic("panic")
+				}()
+			},
+			want: []string{"first", "panic"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu  sync.Mutex
+				got []string
+			)
+			n := NotifierFunc(func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, ent.Message)
+				return nil
+			})
+			clock := newFakeClock()
+			logger := Zap(zapcore.DebugLevel,
+				WithOutput(zapcore.AddSync(ioutil.Discard)),
+				WithNotifier(n),
+				WithSlackLevel(zapcore.ErrorLevel),
+				WithNotificationWorkers(1, 16, Block),
+				WithNotificationRate(tt.perMinute, tt.burst),
+				WithClock(clock),
+			)
+			tt.log(logger, clock)
+			clock.Add(notifyLimitWindow)
+			count := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(got)
+			}
+			eventually(t, func() bool { return count() >= len(tt.want) })
+			if err := logger.Sync(); err != nil {
+				t.Fatal(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// the panics are delivered before the pending notifications.
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("notified %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("notification %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
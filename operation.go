@@ -0,0 +1,112 @@
+package zapx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const logKeyOperation = "logging.googleapis.com/operation"
+
+// operation is additional information about a potentially long-running
+// operation with which a log entry is associated. See
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogEntryOperation
+type operation struct {
+	id       string
+	producer string
+	first    bool
+	last     bool
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (o operation) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("id", o.id)
+	e.AddString("producer", o.producer)
+	if o.first {
+		e.AddBool("first", true)
+	}
+	if o.last {
+		e.AddBool("last", true)
+	}
+	return nil
+}
+
+// Operation constructs a field that associates the entry with the
+// long-running operation id of producer, e.g. a job and its name, so that the
+// entries of a multi-step job are grouped in the Logs Explorer. first and last
+// mark the first and the last entry of the operation. See StartOperation to
+// log an operation from start to end.
+func Operation(id, producer string, first, last bool) zapcore.Field {
+	return zap.Object(logKeyOperation, operation{id: id, producer: producer, first: first, last: last})
+}
+
+// OperationStart is Operation for the first entry of the operation.
+func OperationStart(id, producer string) zapcore.Field {
+	return Operation(id, producer, true, false)
+}
+
+// OperationEnd is Operation for the last entry of the operation.
+func OperationEnd(id, producer string) zapcore.Field {
+	return Operation(id, producer, false, true)
+}
+
+// OperationHandle groups the entries of a long-running operation started by
+// StartOperation.
+type OperationHandle struct {
+	logger *zap.Logger
+	id     string
+	name   string
+	start  time.Time
+}
+
+// StartOperation logs the first entry of the operation name and returns a
+// handle whose End logs the last one. All entries are grouped by the
+// operation id in the Logs Explorer.
+func StartOperation(logger *zap.Logger, name string, fields ...zapcore.Field) *OperationHandle {
+	op := &OperationHandle{
+		logger: logger.With(fields...),
+		id:     newOperationID(),
+		name:   name,
+		start:  time.Now(),
+	}
+	op.logger.WithOptions(zap.AddCallerSkip(1)).Info(name+" started", OperationStart(op.id, name))
+	return op
+}
+
+// ID returns the operation id.
+func (op *OperationHandle) ID() string {
+	return op.id
+}
+
+// Logger returns a logger whose entries belong to the operation.
+func (op *OperationHandle) Logger() *zap.Logger {
+	return op.logger.With(Operation(op.id, op.name, false, false))
+}
+
+// End logs the last entry of the operation with its duration and outcome. A
+// non-nil err marks the operation as failed and is logged at error level.
+func (op *OperationHandle) End(err error, fields ...zapcore.Field) {
+	logger := op.logger.WithOptions(zap.AddCallerSkip(1))
+	fs := append(fields[:len(fields):len(fields)],
+		OperationEnd(op.id, op.name),
+		zap.Duration("duration", time.Since(op.start)),
+	)
+	if err != nil {
+		fs = append(fs, zap.String("outcome", "failure"), zap.Error(err))
+		logger.Error(op.name+" failed", fs...)
+		return
+	}
+	fs = append(fs, zap.String("outcome", "success"))
+	logger.Info(op.name+" finished", fs...)
+}
+
+func newOperationID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -1,19 +1,126 @@
 package zapx
 
 import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lixin9311/backoff/v2"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type option struct {
-	slackURL    string
-	projectID   string
-	service     string
-	version     string
-	errorParser func(error) (zapcore.ObjectMarshaler, bool)
+	slackURL        string
+	projectID       string
+	service         string
+	version         string
+	errorParsers    []func(error) (zapcore.ObjectMarshaler, bool)
+	errorChain      bool
+	redactKeys      []string
+	redactPatterns  []*regexp.Regexp
+	redactQuery     []string
+	requestHeaders  []string
+	maxEntrySize    int
+	stats           Stats
+	onError         errorHandler
+	dedupWindow     time.Duration
+	protoTypes      ProtoResolver
+	protoMax        int
+	rawSpanID       bool
+	alwaysTrace     bool
+	spanEvents      *zapcore.Level
+	traceLinks      bool
+	traceProjectID  string
+	dpanicPanics    bool
+	onFatal         func(zapcore.Entry)
+	onPanic         func(zapcore.Entry)
+	stacktraceLevel *zapcore.Level
+	callerSkip      int
+	level           *zap.AtomicLevel
+	callerPrefix    string
+	moduleCaller    bool
+	fullCaller      bool
+	callerFuncOnly  bool
+	disableCaller   bool
+	detectResource  bool
+	autoDetect      bool
+	routeKey        string
+	routes          map[string]zapcore.WriteSyncer
+	sampleRates     map[zapcore.Level]float64
+	sampleReport    time.Duration
+	sampleInitial   int
+	sampleAfter     int
+	mdMaxValue      int
+	mdMaxTotal      int
+	mdLabels        metadataLabels
+	mdDropRest      bool
+	breakerFailures int
+	breakerCooldown time.Duration
+	notifyLoc       *time.Location
+	metricRecorder  func(name string, value float64)
+	notifiers       []Notifier
+	notifyWorkers   int
+	notifyQueue     int
+	notifyOverflow  OverflowPolicy
+	notifyRate      int
+	notifyBurst     int
+	notifyDedup     time.Duration
+	slackDigest     time.Duration
+	slackToken      string
+	slackChannel    string
+	slackLevels     map[zapcore.Level]string
+	slackBuilder    SlackMessageBuilder
+	retryMax        int
+	retryBackoff    *backoff.Backoff
+	notifyTimeout   time.Duration
+	syncTimeout     time.Duration
+	labels          map[string]string
+	labelProvider   func(zapcore.Entry) map[string]string
+	requestIDKeys   []string
+	auditSink       zapcore.WriteSyncer
+	sampledDebug    bool
+	processors      []func(zapcore.Entry, []zapcore.Field) []zapcore.Field
+	repeatWindow    time.Duration
+	repeatThreshold int
+	deadLetters     DeadLetterFunc
+	mentions        []mentionRule
+	notifyRoutes    []notifyRoute
+	slackLevel      *zapcore.Level
+	errorReporter   func(SinkEnv) (ErrorReporter, error)
+	sinks           []sinkOption
+	syslogNetwork   *string
+	syslogAddr      string
+	syslogTag       string
+	journald        *string
+	fluentNetwork   string
+	fluentAddr      string
+	fluentTag       string
+	file            *lumberjack.Logger
+	bufferSize      int
+	flushInterval   time.Duration
+	cores           []zapcore.Core
+	development     bool
+	encoderConfig   *zapcore.EncoderConfig
+	timeEncoder     zapcore.TimeEncoder
+	output          zapcore.WriteSyncer
+	clock           zapcore.Clock
 }
 
 type Option func(*option)
 
+// project returns id, or the project of the logger if empty.
+func (o *option) project(id string) string {
+	if id != "" {
+		return id
+	}
+	if o.projectID != "" {
+		return o.projectID
+	}
+	return detectProjectID()
+}
+
 // WithSlackURL sets the slack hook url
 func WithSlackURL(url string) Option {
 	return func(o *option) {
@@ -21,6 +128,38 @@ func WithSlackURL(url string) Option {
 	}
 }
 
+// WithSlackURLs sets the slack destinations, webhook urls or channels, by
+// level: an entry goes to the destination of the highest level not above its
+// own, e.g. warn and error entries to the one of warn level and panic and
+// fatal entries to the one of panic level, or to the slack url if none.
+func WithSlackURLs(urls map[zapcore.Level]string) Option {
+	return func(o *option) {
+		o.slackLevels = urls
+	}
+}
+
+// WithSlackMessageBuilder replaces the layout of the Slack notifications,
+// e.g. to choose the blocks, emoji and fields shown.
+func WithSlackMessageBuilder(build SlackMessageBuilder) Option {
+	return func(o *option) {
+		o.slackBuilder = build
+	}
+}
+
+// WithSlackToken posts the Slack notifications with the Web API and the bot
+// token, to channel, a channel name or id, unless a webhook url is set too.
+// Slack can then direct entries to other channels, e.g. Slack("#alerts").
+func WithSlackToken(token, channel string) Option {
+	return func(o *option) {
+		o.slackToken = token
+		o.slackChannel = channel
+	}
+}
+
+// WithProjectID sets the project the traces are qualified with, see
+// WithTraceProjectID. By default, it is read from the GOOGLE_CLOUD_PROJECT,
+// GCP_PROJECT or GCLOUD_PROJECT environment variables, or the metadata server
+// with WithResourceDetection.
 func WithProjectID(id string) Option {
 	return func(o *option) {
 		o.projectID = id
@@ -39,8 +178,713 @@ func WithVersion(ver string) Option {
 	}
 }
 
+// WithErrorParser renders the errors logged that parser accepts as the object
+// it returns, instead of their message. The option may be repeated, e.g. by
+// libraries rendering their own errors, the parsers being tried in order
+// until one accepts the error. See ErrorParserFor.
 func WithErrorParser(parser func(error) (zapcore.ObjectMarshaler, bool)) Option {
 	return func(o *option) {
-		o.errorParser = parser
+		o.errorParsers = append(o.errorParsers, parser)
+	}
+}
+
+// WithErrorChain adds the chain of every error logged, see ErrorChain, under
+// the key of the error suffixed with "_chain", e.g. "error_chain".
+func WithErrorChain() Option {
+	return func(o *option) {
+		o.errorChain = true
+	}
+}
+
+// WithRedaction masks the values of the fields named after one of keys,
+// case-insensitively, and the parts of the messages and of the string values
+// matching one of patterns, e.g. EmailPattern, CreditCardPattern and
+// BearerTokenPattern, in the fields and their nested objects and arrays,
+// before the entries are written, notified or reported. The option may be
+// repeated. See Sensitive to mask a single field.
+func WithRedaction(keys []string, patterns ...*regexp.Regexp) Option {
+	return func(o *option) {
+		o.redactKeys = append(o.redactKeys, keys...)
+		o.redactPatterns = append(o.redactPatterns, patterns...)
+	}
+}
+
+// WithRedactedQueryParams masks the values of the query parameters params,
+// case-insensitively, e.g. "token" or "key", in the urls and referers of the
+// requests logged with Request and reported.
+func WithRedactedQueryParams(params ...string) Option {
+	return func(o *option) {
+		o.redactQuery = append(o.redactQuery, params...)
+	}
+}
+
+// WithRequestHeaders logs the headers of the requests logged with Request, if
+// set, under "requestHeaders". Authorization, Proxy-Authorization, Cookie and
+// Set-Cookie are always masked.
+func WithRequestHeaders(headers ...string) Option {
+	return func(o *option) {
+		o.requestHeaders = append(o.requestHeaders, headers...)
+	}
+}
+
+// WithMaxEntrySize keeps the entries under maxBytes once encoded, e.g. below
+// the 256 kB Cloud Logging rejects: the message and the largest fields of an
+// entry too large are truncated, and the entry is labeled with
+// zapx_truncated. The trace, labels, source location and request of the
+// entry are kept whole. The size is measured by encoding every entry once
+// more.
+func WithMaxEntrySize(maxBytes int) Option {
+	return func(o *option) {
+		o.maxEntrySize = maxBytes
+	}
+}
+
+// WithInternalErrorHandler sets the handler of the failures of zapx itself,
+// e.g. the notifications that could not be delivered or the entries that
+// could not be sent to Cloud Logging, logged by grpclog by default.
+func WithInternalErrorHandler(handler func(error)) Option {
+	return func(o *option) {
+		o.onError = handler
+	}
+}
+
+// WithLabels attaches the labels to every entry, e.g. the environment or the
+// team, in logging.googleapis.com/labels. The labels of the entries, see
+// Label, take precedence. WithLabels may be repeated.
+func WithLabels(lbs map[string]string) Option {
+	return func(o *option) {
+		if o.labels == nil {
+			o.labels = make(map[string]string, len(lbs))
+		}
+		for k, v := range lbs {
+			o.labels[k] = v
+		}
+	}
+}
+
+// WithLabelProvider attaches the labels returned by provider when the entry
+// is written, e.g. derived from feature flags or the rollout cohort. The
+// labels of WithLabels, of the loggers and of the entries take precedence.
+// provider is called for every entry written, and must be fast and safe for
+// concurrent use.
+func WithLabelProvider(provider func(zapcore.Entry) map[string]string) Option {
+	return func(o *option) {
+		o.labelProvider = provider
+	}
+}
+
+// WithRequestIDKeys looks the request ids up in the incoming metadata, or the
+// headers of the requests, under keys in order, e.g. "x-request-id" then
+// "x-correlation-id", instead of RequestIDMetadataKey. The client interceptors
+// forward the request id under the first key.
+func WithRequestIDKeys(keys ...string) Option {
+	return func(o *option) {
+		o.requestIDKeys = make([]string, len(keys))
+		for i, key := range keys {
+			o.requestIDKeys[i] = strings.ToLower(key)
+		}
+	}
+}
+
+// WithAuditSink writes the audit entries, see Audit, to ws instead of stdout,
+// e.g. a file with its own retention.
+func WithAuditSink(ws zapcore.WriteSyncer) Option {
+	return func(o *option) {
+		o.auditSink = ws
+	}
+}
+
+// WithSampledTraceDebug writes the entries below the level of the logger,
+// down to debug, when their trace is sampled, see Context, so that the
+// sampled requests are logged in full without enabling debug globally. The
+// entries of the loggers whose trace is not sampled, see ForRequest, are
+// dropped as cheaply as below the level, the others once their fields are
+// looked up for a trace.
+func WithSampledTraceDebug() Option {
+	return func(o *option) {
+		o.sampledDebug = true
+	}
+}
+
+// WithFieldProcessor rewrites the fields of every entry written with
+// process, before the special fields are resolved, e.g. to promote a field
+// to a label, see Label, or to drop it. The processors are chained in the
+// order of the options. The fields attached with With are not passed, and
+// process must not modify fields in place but return a new slice.
+func WithFieldProcessor(process func(ent zapcore.Entry, fields []zapcore.Field) []zapcore.Field) Option {
+	return func(o *option) {
+		o.processors = append(o.processors, process)
+	}
+}
+
+// WithFieldLevels drops the fields of the entries below their level in
+// levels, keyed by field key, e.g. {"metadata": zapcore.WarnLevel} logs the
+// metadata, see Metadata, of the warn entries and above only, to keep the
+// high-volume info entries small. The fields attached with With are kept, see
+// WithFieldProcessor.
+func WithFieldLevels(levels map[string]zapcore.Level) Option {
+	minLevels := make(map[string]zapcore.Level, len(levels))
+	for key, l := range levels {
+		minLevels[key] = l
+	}
+	return WithFieldProcessor(func(ent zapcore.Entry, fields []zapcore.Field) []zapcore.Field {
+		for i, f := range fields {
+			if l, ok := minLevels[f.Key]; ok && ent.Level < l {
+				// copy on the first field dropped only
+				out := append(make([]zapcore.Field, 0, len(fields)-1), fields[:i]...)
+				for _, f := range fields[i+1:] {
+					if l, ok := minLevels[f.Key]; !ok || ent.Level >= l {
+						out = append(out, f)
+					}
+				}
+				return out
+			}
+		}
+		return fields
+	})
+}
+
+// WithRepeatAggregation collapses the bursts of identical entries, writing
+// at most threshold entries of the same level, caller and message per window
+// and a summary of the others, see NewRepeatCore.
+func WithRepeatAggregation(window time.Duration, threshold int) Option {
+	return func(o *option) {
+		o.repeatWindow = window
+		o.repeatThreshold = threshold
+	}
+}
+
+// WithStats reports the entries written and dropped, and the delivery of the
+// notifications, to stats, e.g. the Prometheus metrics of the promx package.
+func WithStats(stats Stats) Option {
+	return func(o *option) {
+		o.stats = stats
+	}
+}
+
+// WithDedup collapses identical consecutive entries (same level, message and
+// caller) written within window. The first entry is written immediately; the
+// repeated ones are flushed as one entry carrying the number of
+// "occurrences" of the window, the first entry included, when a different
+// entry is written, when the window expires, or on Sync.
+func WithDedup(window time.Duration) Option {
+	return func(o *option) {
+		o.dedupWindow = window
+	}
+}
+
+// WithProtoResolver sets the resolver used to expand google.protobuf.Any
+// messages logged by Proto. It defaults to protoregistry.GlobalTypes.
+func WithProtoResolver(r ProtoResolver) Option {
+	return func(o *option) {
+		o.protoTypes = r
+	}
+}
+
+// WithProtoMaxBytes caps the size of the messages logged by Proto. Larger
+// messages are replaced by a summary of their type, size and a truncated
+// preview.
+func WithProtoMaxBytes(n int) Option {
+	return func(o *option) {
+		o.protoMax = n
+	}
+}
+
+// WithRawSpanID keeps the span ids as received in the x-cloud-trace-context
+// header, instead of normalizing them to the 16-char hex form Cloud Logging
+// expects.
+func WithRawSpanID() Option {
+	return func(o *option) {
+		o.rawSpanID = true
+	}
+}
+
+// WithAlwaysTrace emits the trace and span ids even when the trace is not
+// sampled, so that the entries of unsampled requests can still be correlated.
+func WithAlwaysTrace() Option {
+	return func(o *option) {
+		o.alwaysTrace = true
+	}
+}
+
+// WithSpanEvents records the entries at or above level as events of the span
+// carried by the Context field, so that they show up inline in trace views.
+// Both OpenTelemetry and OpenCensus spans are supported.
+func WithSpanEvents(level zapcore.Level) Option {
+	return func(o *option) {
+		o.spanEvents = &level
+	}
+}
+
+// WithTraceLinks adds a "trace_url" field linking to the trace in the Cloud
+// Trace console of the project set by WithProjectID, handy when reading the
+// logs locally.
+func WithTraceLinks() Option {
+	return func(o *option) {
+		o.traceLinks = true
+	}
+}
+
+// WithTraceProjectID sets the project the traces are stored in, when it
+// differs from the logging project set by WithProjectID, e.g. in shared VPC
+// setups. The trace is emitted as projects/<id>/traces/<trace id>.
+func WithTraceProjectID(id string) Option {
+	return func(o *option) {
+		o.traceProjectID = id
+	}
+}
+
+// WithDPanicPanics controls whether DPanic entries panic after being written,
+// as in development. By default they are logged as CRITICAL and the program
+// continues.
+func WithDPanicPanics(panics bool) Option {
+	return func(o *option) {
+		o.dpanicPanics = panics
+	}
+}
+
+// WithOnFatal registers a hook called with the Fatal entries once they are
+// written, before the process exits, e.g. to flush traces and metrics. The
+// notifications, the pending ones included, are delivered before, within 3s.
+func WithOnFatal(hook func(zapcore.Entry)) Option {
+	return func(o *option) {
+		o.onFatal = hook
+	}
+}
+
+// WithOnPanic registers a hook called with the Panic entries once they are
+// written, before the logger panics. The panics recovered by Recover are left
+// out.
+func WithOnPanic(hook func(zapcore.Entry)) Option {
+	return func(o *option) {
+		o.onPanic = hook
+	}
+}
+
+// WithStacktraceLevel captures the stack traces of the entries at or above
+// level, e.g. Error in production and Warn in staging. They are reported to
+// Error Reporting along with the message. No stack trace is captured by
+// default.
+func WithStacktraceLevel(level zapcore.Level) Option {
+	return func(o *option) {
+		o.stacktraceLevel = &level
+	}
+}
+
+// WithDisableCaller does not capture the callers of the entries, sparing the
+// cost of runtime.Caller on hot paths. The entries carry neither the caller
+// nor the sourceLocation and reportLocation.
+func WithDisableCaller() Option {
+	return func(o *option) {
+		o.disableCaller = true
+	}
+}
+
+// WithCallerSkip skips n more frames when reporting the caller, e.g. in a
+// library wrapping the logger, so that the sourceLocation and reportLocation
+// point at the call site rather than at the wrapper.
+func WithCallerSkip(n int) Option {
+	return func(o *option) {
+		o.callerSkip += n
+	}
+}
+
+// WithModuleRelativeCaller reports the file paths of the sourceLocation and
+// reportLocation relative to the root of the main module, so that they map
+// onto repository paths, instead of zap's package/file.go:line.
+func WithModuleRelativeCaller() Option {
+	return func(o *option) {
+		o.moduleCaller = true
+	}
+}
+
+// WithCallerPrefix is like WithModuleRelativeCaller, but the file paths are
+// reported relative to prefix, e.g. "/src/".
+func WithCallerPrefix(prefix string) Option {
+	return func(o *option) {
+		o.callerPrefix = prefix
+	}
+}
+
+// WithFullCallerPath reports the full file path of the caller, both in the
+// caller key and in the sourceLocation and reportLocation.
+func WithFullCallerPath() Option {
+	return func(o *option) {
+		o.fullCaller = true
+	}
+}
+
+// WithFunctionOnlyCaller reports only the fully qualified function name in
+// the sourceLocation and reportLocation, omitting the file and line.
+func WithFunctionOnlyCaller() Option {
+	return func(o *option) {
+		o.callerFuncOnly = true
+	}
+}
+
+// WithResourceDetection detects the monitored resource the logger runs on, a
+// Cloud Function, a Cloud Run revision, a GKE container or a GCE instance,
+// once when the logger is built, and attaches it to every entry under
+// "resource".
+func WithResourceDetection() Option {
+	return func(o *option) {
+		o.detectResource = true
+	}
+}
+
+// WithAutoDetect detects the project, the service and the version from the
+// environment variables of Cloud Run, Cloud Functions and App Engine, and
+// the project from the metadata server, once when the logger is built, for
+// the ones not set by WithProjectID, WithService and WithVersion. The region
+// and the zone of the metadata server are attached to every entry as labels.
+func WithAutoDetect() Option {
+	return func(o *option) {
+		o.autoDetect = true
+	}
+}
+
+// WithClock sets the clock of the entries and of the notifications, e.g. a
+// frozen clock in tests.
+func WithClock(clock zapcore.Clock) Option {
+	return func(o *option) {
+		o.clock = clock
+	}
+}
+
+// WithOutput writes the entries to ws instead of stdout, e.g. a buffer in
+// tests. The writes are serialized, see zapcore.Lock.
+func WithOutput(ws zapcore.WriteSyncer) Option {
+	return func(o *option) {
+		o.output = ws
+	}
+}
+
+// WithEncoderConfig replaces StackdriverEncoderConfig, e.g. to rename keys.
+// Cloud Logging, and the sinks of the logger, expect the severity and the
+// time under the keys of StackdriverEncoderConfig.
+func WithEncoderConfig(cfg zapcore.EncoderConfig) Option {
+	return func(o *option) {
+		o.encoderConfig = &cfg
+	}
+}
+
+// WithTimeEncoder sets the encoder of the time of the entries, e.g.
+// zapcore.RFC3339NanoTimeEncoder, over the encoder config.
+func WithTimeEncoder(enc zapcore.TimeEncoder) Option {
+	return func(o *option) {
+		o.timeEncoder = enc
+	}
+}
+
+// WithDevelopment writes human readable colored lines instead of JSON, for
+// local development. The entries below warn level are not enriched, see
+// Minimal; the labels, error parsing and notifications work as usual.
+func WithDevelopment() Option {
+	return func(o *option) {
+		o.development = true
+	}
+}
+
+// WithCores also writes the entries to cores, e.g. an observer in tests, as
+// they are written to stdout, i.e. with the fields added by the logger. Each
+// core only gets the entries it enables.
+func WithCores(cores ...zapcore.Core) Option {
+	return func(o *option) {
+		o.cores = append(o.cores, cores...)
+	}
+}
+
+// WithBuffer buffers up to size bytes of entries, 256 kB if zero, in front of
+// stdout, flushed every flushInterval, 30 seconds if zero, by Sync, and
+// before the panic and fatal entries return. It saves syscalls on hot paths
+// at the cost of losing the buffered entries on a crash.
+func WithBuffer(size int, flushInterval time.Duration) Option {
+	return func(o *option) {
+		o.bufferSize = size
+		o.flushInterval = flushInterval
+		if o.bufferSize == 0 && o.flushInterval == 0 {
+			// the zero buffer is disabled.
+			o.bufferSize = 256 * 1024
+		}
+	}
+}
+
+// WithFile also writes the entries, as written to stdout, to the file at path,
+// rotated once it reaches maxSizeMB megabytes. At most maxBackups rotated
+// files are kept, for at most maxAge days; zero keeps them all.
+func WithFile(path string, maxSizeMB, maxBackups, maxAge int) Option {
+	return func(o *option) {
+		o.file = &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSizeMB,
+			MaxBackups: maxBackups,
+			MaxAge:     maxAge,
+		}
+	}
+}
+
+// WithSyslog also sends the entries, as written to stdout, to the syslog
+// server at raddr as RFC 5424 messages with the user facility, their severity
+// mapped from the level and tag as app name. The network is "udp", "tcp" or
+// "unix"; if empty, the entries go to the local syslog daemon.
+func WithSyslog(network, raddr, tag string) Option {
+	return func(o *option) {
+		o.syslogNetwork = &network
+		o.syslogAddr = raddr
+		o.syslogTag = tag
+	}
+}
+
+// WithJournald also sends the entries to the local journald, e.g. on VMs
+// managed by systemd, with the severity of the entries as PRIORITY, the trace,
+// span, request id, service and version as the TRACE_ID, SPAN_ID, REQUEST_ID,
+// SERVICE and VERSION fields, the labels as LABEL_ fields, and the entry as
+// written to stdout as ENTRY. The identifier defaults to the service.
+func WithJournald(identifier string) Option {
+	return func(o *option) {
+		o.journald = &identifier
+	}
+}
+
+// WithFluentForward also forwards the entries, as written to stdout, to
+// fluentd or Fluent Bit at addr on network, "tcp" or "unix", over the Forward
+// protocol under tag, e.g. "app.api". The entries are sent in chunks
+// acknowledged by the server, and kept buffered until they are, reconnecting
+// on failure; the logging calls block while the buffer is full, up to a
+// second after which the entry is dropped. Sync forwards the entries
+// buffered.
+func WithFluentForward(network, addr, tag string) Option {
+	return func(o *option) {
+		o.fluentNetwork = network
+		o.fluentAddr = addr
+		o.fluentTag = tag
+	}
+}
+
+// WithErrorReporter reports the entries at error level or above to the
+// reporter built by build, e.g. by errorreportingx, with the error, the user,
+// the request and the stack trace of the entry, in addition to writing them.
+// The entries terminating the process are reported before it terminates.
+func WithErrorReporter(build func(env SinkEnv) (ErrorReporter, error)) Option {
+	return func(o *option) {
+		o.errorReporter = build
+	}
+}
+
+// WithRoutes writes the entries carrying the string field key to the writer
+// of its value in routes, e.g. per tenant, instead of stdout. Entries without
+// the field, or with an unknown value, are written to stdout.
+func WithRoutes(key string, routes map[string]zapcore.WriteSyncer) Option {
+	return func(o *option) {
+		o.routeKey = key
+		o.routes = routes
+	}
+}
+
+// WithLevelSampling keeps only a fraction of the entries of each level, e.g.
+// {Debug: 0.01, Info: 0.1}; the levels missing from rates are all kept. Every
+// report interval, the number of entries dropped per level is written in an
+// info entry, unless report is 0.
+func WithLevelSampling(rates map[zapcore.Level]float64, report time.Duration) Option {
+	return func(o *option) {
+		o.sampleRates = rates
+		o.sampleReport = report
+	}
+}
+
+// WithAtomicLevel makes the logger use lvl as its level, set to the level
+// passed to Zap, so that the level can be changed at runtime, e.g. by serving
+// lvl over HTTP, or shared by several loggers. See ZapWithLevel.
+func WithAtomicLevel(lvl zap.AtomicLevel) Option {
+	return func(o *option) {
+		o.level = &lvl
+	}
+}
+
+// WithSampling caps the entries of the same level and message to initial per
+// second, then keeps one out of every thereafter, or none if 0, so that an
+// error logged in a tight loop does not write millions of identical entries.
+// The entries dropped are neither written nor notified.
+func WithSampling(initial, thereafter int) Option {
+	return func(o *option) {
+		o.sampleInitial = initial
+		o.sampleAfter = thereafter
+	}
+}
+
+// WithMetadataLimits truncates the values logged by Metadata and
+// OutgoingMetadata to maxValue bytes each, e.g. to keep cookies and JWTs from
+// bloating the entries, and drops the keys beyond maxTotal bytes. Zero means
+// no limit.
+func WithMetadataLimits(maxValue, maxTotal int) Option {
+	return func(o *option) {
+		o.mdMaxValue = maxValue
+		o.mdMaxTotal = maxTotal
+	}
+}
+
+// WithMetadataLabels promotes the keys of the metadata logged by Metadata to
+// labels, mapping, e.g. "x-tenant-id" to "tenant_id", with their first value.
+// If dropRest, the metadata object is not logged, only the labels promoted.
+// The keys of the metadata are lower case.
+func WithMetadataLabels(mapping map[string]string, dropRest bool) Option {
+	return func(o *option) {
+		o.mdLabels = make(metadataLabels, len(mapping))
+		for key, label := range mapping {
+			o.mdLabels[strings.ToLower(key)] = label
+		}
+		o.mdDropRest = dropRest
+	}
+}
+
+// WithCircuitBreaker stops sending notifications to a notifier after failures
+// consecutive delivery failures, for the cool-down period, after which a
+// single notification probes whether the delivery works again. The other
+// notifiers are not affected.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(o *option) {
+		o.breakerFailures = failures
+		o.breakerCooldown = cooldown
+	}
+}
+
+// WithNotificationLocation renders the timestamps of the notifications in
+// loc, e.g. the time zone of the on-call team, instead of the local time zone.
+func WithNotificationLocation(loc *time.Location) Option {
+	return func(o *option) {
+		o.notifyLoc = loc
+	}
+}
+
+// WithMetricRecorder calls record with the metrics of every entry written, see
+// Metric, e.g. to increment a Prometheus or Cloud Monitoring counter along
+// with the log-based metric.
+func WithMetricRecorder(record func(name string, value float64)) Option {
+	return func(o *option) {
+		o.metricRecorder = record
+	}
+}
+
+// WithNotifier adds an alerting backend notified of the entries marked with
+// Slack, along with the slack url if any.
+func WithNotifier(n Notifier) Option {
+	return func(o *option) {
+		o.notifiers = append(o.notifiers, n)
+	}
+}
+
+// WithNotificationWorkers delivers the notifications with the given number of
+// workers, 4 by default, from a queue of the given depth, 256 by default,
+// applying policy when the queue is full.
+func WithNotificationWorkers(workers, depth int, policy OverflowPolicy) Option {
+	return func(o *option) {
+		o.notifyWorkers = workers
+		o.notifyQueue = depth
+		o.notifyOverflow = policy
+	}
+}
+
+// WithNotificationRate limits the notifications to perMinute per minute, with
+// bursts of up to burst notifications, perMinute if zero. The notifications
+// dropped are counted and reported by a "suppressed" summary notification
+// once a minute. Panic and fatal entries are always notified.
+func WithNotificationRate(perMinute, burst int) Option {
+	return func(o *option) {
+		o.notifyRate = perMinute
+		o.notifyBurst = burst
+	}
+}
+
+// WithNotificationDedup collapses the notifications of the entries sharing the
+// same message, caller and error within window: the first one is sent right
+// away, and the following ones as a single notification carrying the number
+// of "occurrences" when the window ends.
+func WithNotificationDedup(window time.Duration) Option {
+	return func(o *option) {
+		o.notifyDedup = window
+	}
+}
+
+// WithSlackDigest aggregates the Slack notifications over interval, and posts
+// them as a single message listing the entries grouped by level, for services
+// where per-entry notifications are too noisy.
+func WithSlackDigest(interval time.Duration) Option {
+	return func(o *option) {
+		o.slackDigest = interval
+	}
+}
+
+// WithSlackRetry retries the delivery of a notification up to max times,
+// waiting as per bo in between, instead of 10 times with an exponential
+// backoff from 1s to 30s. A max of 0 disables the retries, e.g. in tests.
+func WithSlackRetry(max int, bo backoff.Backoff) Option {
+	return func(o *option) {
+		o.retryMax = max
+		o.retryBackoff = &bo
+	}
+}
+
+// WithSlackTimeout bounds the delivery of a notification, retries included, to
+// d instead of 10s.
+func WithSlackTimeout(d time.Duration) Option {
+	return func(o *option) {
+		o.notifyTimeout = d
+	}
+}
+
+// WithSyncTimeout bounds the wait of Sync for the pending notifications to d
+// instead of 15s, after which Sync returns an error with the number of
+// notifications abandoned. A negative d waits for them indefinitely.
+func WithSyncTimeout(d time.Duration) Option {
+	return func(o *option) {
+		o.syncTimeout = d
+	}
+}
+
+// WithDeadLetter hands the notifications that could not be delivered, after
+// the retries or while the circuit breaker is open, to sink, e.g.
+// DeadLetterDir or DeadLetterFile, for later replay.
+func WithDeadLetter(sink DeadLetterFunc) Option {
+	return func(o *option) {
+		o.deadLetters = sink
+	}
+}
+
+// WithSlackMention mentions someone in the Slack notifications of the entries
+// matching, e.g. "<!here>" for MatchLevel(zapcore.PanicLevel), or the user
+// group "<!subteam^ID>" for MatchLabel("team", "payments"). The option may be
+// repeated, every rule matching adds its mention.
+func WithSlackMention(match Matcher, mention string) Option {
+	return func(o *option) {
+		o.mentions = append(o.mentions, mentionRule{match: match, mention: mention})
+	}
+}
+
+// WithNotificationRoute notifies the entries matching, e.g.
+// MatchLabel("team", "infra"), with dest instead of the notifiers of the
+// logger. The option may be repeated: an entry is notified by the
+// destinations of all the routes matching it, and by the notifiers of the
+// logger if none does. Only the entries marked for notification, see Slack
+// and WithSlackLevel, are routed.
+func WithNotificationRoute(match Matcher, dest Notifier) Option {
+	return func(o *option) {
+		o.notifyRoutes = append(o.notifyRoutes, notifyRoute{match: match, notifier: dest})
+	}
+}
+
+// WithSlackRoute is like WithNotificationRoute, the entries matching being
+// notified to the slack destination url, a webhook url or a channel, e.g.
+// the billing channel for MatchLabel("team", "billing").
+func WithSlackRoute(match Matcher, url string) Option {
+	return func(o *option) {
+		o.notifyRoutes = append(o.notifyRoutes, notifyRoute{match: match, slackURL: url})
+	}
+}
+
+// WithSlackLevel notifies every entry at level or above, without Slack. An
+// entry, or a logger with With, can still opt out with NoSlack.
+func WithSlackLevel(level zapcore.Level) Option {
+	return func(o *option) {
+		o.slackLevel = &level
 	}
 }
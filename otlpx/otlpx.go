@@ -0,0 +1,355 @@
+// Package otlpx exports the zapx entries as OpenTelemetry log records to a
+// collector, e.g. to feed other backends than Cloud Logging.
+package otlpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lixin9311/zapx"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// batchSize is the number of records buffered before they are
+	// exported.
+	batchSize = 512
+	// flushInterval is the period of the export of the records buffered.
+	flushInterval = 5 * time.Second
+	// exportTimeout bounds each export.
+	exportTimeout = 10 * time.Second
+)
+
+// severities are the OpenTelemetry severity numbers of the stackdriver
+// severities.
+var severities = map[string]logspb.SeverityNumber{
+	"DEBUG":     logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG,
+	"INFO":      logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+	"NOTICE":    logspb.SeverityNumber_SEVERITY_NUMBER_INFO2,
+	"WARNING":   logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+	"ERROR":     logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+	"CRITICAL":  logspb.SeverityNumber_SEVERITY_NUMBER_FATAL,
+	"ALERT":     logspb.SeverityNumber_SEVERITY_NUMBER_FATAL2,
+	"EMERGENCY": logspb.SeverityNumber_SEVERITY_NUMBER_FATAL3,
+}
+
+func init() {
+	zapx.RegisterSink("otlp", func(c zapx.SinkConfig) (zapx.Option, error) {
+		if c["endpoint"] == "" {
+			return nil, errors.New("otlpx: otlp needs an endpoint")
+		}
+		protocol := c["protocol"]
+		if protocol == "" {
+			protocol = "grpc"
+		}
+		return WithSink(protocol, c["endpoint"]), nil
+	})
+}
+
+// WithSink also exports the entries as OpenTelemetry log records to the
+// collector at endpoint over protocol, "grpc" or "http", batched and
+// asynchronously. For grpc, endpoint is the address of the collector, e.g.
+// "collector:4317", over TLS unless prefixed with "http://". For http, it is
+// the url of the collector, e.g. "http://collector:4318", the path
+// defaulting to /v1/logs. The service context is exported as the resource,
+// and the labels and the fields as the attributes of the records. Sync
+// exports the records buffered. It is configured as the "otlp" sink of
+// zapx.Config, with the "protocol", grpc by default, and "endpoint" keys.
+func WithSink(protocol, endpoint string) zapx.Option {
+	return zapx.WithSink(func(env zapx.SinkEnv) (zapx.Sink, error) {
+		return newSink(protocol, endpoint, env.ServiceContext, env.OnError)
+	})
+}
+
+// sink is a zapx.Sink exporting the entries encoded by the core as OTLP log
+// records to an OpenTelemetry Collector, batched and asynchronously. The
+// service context is exported as the resource, the labels and the other
+// fields as the attributes of the records.
+type sink struct {
+	export   func(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	conn     *grpc.ClientConn
+	resource *resourcepb.Resource
+	onError  func(error)
+
+	mu      sync.Mutex
+	records []*logspb.LogRecord
+	full    chan struct{}
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// newSink returns a sink exporting to endpoint over protocol, "grpc" or
+// "http", see WithSink.
+func newSink(protocol, endpoint string, svcCtx zapx.ServiceContext, onError func(error)) (*sink, error) {
+	s := &sink{
+		resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			stringAttribute("service.name", svcCtx.Service),
+			stringAttribute("service.version", svcCtx.Version),
+		}},
+		onError: onError,
+		full:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	switch protocol {
+	case "grpc":
+		target, creds := endpoint, credentials.NewTLS(&tls.Config{})
+		if u, err := url.Parse(endpoint); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			target = u.Host
+			if u.Scheme == "http" {
+				creds = insecure.NewCredentials()
+			}
+		}
+		conn, err := grpc.Dial(target, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("otlpx: failed to dial the collector: %w", err)
+		}
+		client := collogspb.NewLogsServiceClient(conn)
+		s.conn = conn
+		s.export = func(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+			_, err := client.Export(ctx, req)
+			return err
+		}
+	case "http":
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("otlpx: invalid endpoint: %w", err)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/logs"
+		}
+		s.export = httpExporter(u.String())
+	default:
+		return nil, fmt.Errorf("otlpx: unknown otlp protocol %q", protocol)
+	}
+	s.stopped.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// httpExporter returns an exporter posting the requests to u, protobuf
+// encoded.
+func httpExporter(u string) func(context.Context, *collogspb.ExportLogsServiceRequest) error {
+	return func(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+		body, err := proto.Marshal(req)
+		if err != nil {
+			return err
+		}
+		hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		hreq.Header.Set("Content-Type", "application/x-protobuf")
+		resp, err := http.DefaultClient.Do(hreq)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+			return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+		}
+		return nil
+	}
+}
+
+// run exports the records buffered periodically, and once the batch is full.
+func (s *sink) run() {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.full:
+		case <-s.done:
+			return
+		}
+		if err := s.Sync(); err != nil {
+			s.onError(fmt.Errorf("otlpx: failed to export log records: %w", err))
+		}
+	}
+}
+
+// Write buffers the record of the entry encoded in p.
+func (s *sink) Write(p []byte) (int, error) {
+	record, err := logRecord(p)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	s.records = append(s.records, record)
+	full := len(s.records) >= batchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Sync exports the records buffered.
+func (s *sink) Sync() error {
+	s.mu.Lock()
+	records := s.records
+	s.records = nil
+	s.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	return s.export(ctx, &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: s.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: "github.com/lixin9311/zapx"},
+				LogRecords: records,
+			}},
+		}},
+	})
+}
+
+// Close exports the records buffered and closes the connection.
+func (s *sink) Close() error {
+	close(s.done)
+	s.stopped.Wait()
+	err := s.Sync()
+	if s.conn != nil {
+		if cerr := s.conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// logRecord returns the log record of an entry encoded by the core.
+func logRecord(p []byte) (*logspb.LogRecord, error) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	take := func(key string) (string, bool) {
+		v, ok := m[key].(string)
+		delete(m, key)
+		return v, ok
+	}
+	r := &logspb.LogRecord{ObservedTimeUnixNano: uint64(time.Now().UnixNano())}
+	if severity, ok := take(zapx.StackdriverEncoderConfig.LevelKey); ok {
+		r.SeverityText = severity
+		r.SeverityNumber = severities[severity]
+	}
+	if eventTime, ok := take(zapx.StackdriverEncoderConfig.TimeKey); ok {
+		if t, err := parseEventTime(eventTime); err == nil {
+			r.TimeUnixNano = uint64(t.UnixNano())
+		}
+	}
+	if msg, ok := take(zapx.StackdriverEncoderConfig.MessageKey); ok {
+		r.Body = &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: msg}}
+	}
+	if trace, ok := take("logging.googleapis.com/trace"); ok {
+		// projects/[PROJECT_ID]/traces/[TRACE_ID], see zapx.WithProjectID
+		r.TraceId, _ = hex.DecodeString(trace[strings.LastIndex(trace, "/")+1:])
+	}
+	if spanID, ok := take("logging.googleapis.com/spanId"); ok {
+		r.SpanId, _ = hex.DecodeString(spanID)
+	}
+	if sampled, _ := m["logging.googleapis.com/trace_sampled"].(bool); sampled {
+		// the sampled bit of the W3C trace flags
+		r.Flags = 1
+	}
+	delete(m, "logging.googleapis.com/trace_sampled")
+	// in the resource
+	delete(m, "serviceContext")
+	if lbs, ok := m["logging.googleapis.com/labels"].(map[string]interface{}); ok {
+		for key, val := range lbs {
+			r.Attributes = append(r.Attributes, attribute(key, val))
+		}
+	}
+	delete(m, "logging.googleapis.com/labels")
+	if loc, ok := m["logging.googleapis.com/sourceLocation"].(map[string]interface{}); ok {
+		r.Attributes = append(r.Attributes,
+			attribute("code.filepath", loc["file"]),
+			attribute("code.lineno", loc["line"]),
+			attribute("code.function", loc["function"]),
+		)
+	}
+	delete(m, "logging.googleapis.com/sourceLocation")
+	for key, val := range m {
+		r.Attributes = append(r.Attributes, attribute(key, val))
+	}
+	return r, nil
+}
+
+// stringAttribute returns the string attribute key.
+func stringAttribute(key, val string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val}}}
+}
+
+// attribute returns the attribute key of a value decoded from JSON.
+func attribute(key string, val interface{}) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: value(val)}
+}
+
+// value returns the value of a value decoded from JSON, with the numbers
+// as json.Number.
+func value(val interface{}) *commonpb.AnyValue {
+	switch v := val.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: i}}
+		}
+		f, _ := v.Float64()
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: f}}
+	case []interface{}:
+		arr := &commonpb.ArrayValue{Values: make([]*commonpb.AnyValue, len(v))}
+		for i, elem := range v {
+			arr.Values[i] = value(elem)
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: arr}}
+	case map[string]interface{}:
+		kvs := &commonpb.KeyValueList{Values: make([]*commonpb.KeyValue, 0, len(v))}
+		for key, elem := range v {
+			kvs.Values = append(kvs.Values, attribute(key, elem))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: kvs}}
+	default:
+		// null
+		return &commonpb.AnyValue{}
+	}
+}
+
+// parseEventTime parses the time of an entry encoded by the zapx core, with
+// zapcore.ISO8601TimeEncoder or an RFC 3339 encoder.
+func parseEventTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02T15:04:05.000Z0700", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,115 @@
+package otlpx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lixin9311/zapx"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector records the batches of records exported to it.
+type collector struct {
+	mu      sync.Mutex
+	batches [][]*logspb.LogRecord
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collogspb.ExportLogsServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches = append(c.batches, req.ResourceLogs[0].ScopeLogs[0].LogRecords)
+}
+
+func (c *collector) sizes() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sizes := make([]int, len(c.batches))
+	for i, b := range c.batches {
+		sizes[i] = len(b)
+	}
+	return sizes
+}
+
+func TestSinkBatching(t *testing.T) {
+	tests := []struct {
+		name    string
+		records int
+		sync    bool
+		// want is the number of records exported.
+		want int
+	}{
+		{"buffered until synced", 3, false, 0},
+		{"synced", 3, true, 3},
+		{"exported once the batch is full", batchSize, false, batchSize},
+		{"full batch then synced", batchSize + 2, true, batchSize + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := new(collector)
+			srv := httptest.NewServer(c)
+			defer srv.Close()
+			s, err := newSink("http", srv.URL, zapx.ServiceContext{Service: "svc"}, func(err error) { t.Error(err) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			entry := []byte(`{"severity":"ERROR","message":"boom","logging.googleapis.com/trace":"projects/p/traces/0102030405060708090a0b0c0d0e0f10"}`)
+			for i := 0; i < tt.records; i++ {
+				if _, err := s.Write(entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.records >= batchSize {
+				deadline := time.Now().Add(time.Second)
+				for len(c.sizes()) == 0 && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			if tt.sync {
+				if err := s.Sync(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got int
+			for _, n := range c.sizes() {
+				got += n
+			}
+			if got != tt.want {
+				t.Errorf("exported %d records, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogRecord(t *testing.T) {
+	r, err := logRecord([]byte(`{"severity":"WARNING","message":"hi","logging.googleapis.com/trace":"projects/p/traces/0102030405060708090a0b0c0d0e0f10","logging.googleapis.com/labels":{"team":"a"},"n":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_WARN || r.Body.GetStringValue() != "hi" || len(r.TraceId) != 16 {
+		t.Errorf("record = %v", r)
+	}
+	attrs := make(map[string]bool)
+	for _, kv := range r.Attributes {
+		attrs[kv.Key] = true
+	}
+	if !attrs["team"] || !attrs["n"] {
+		t.Errorf("attributes = %v", r.Attributes)
+	}
+}
@@ -0,0 +1,141 @@
+package zapx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyNotifier triggers PagerDuty incidents with the Events API v2.
+type pagerDutyNotifier struct {
+	url        string
+	routingKey string
+	source     string
+	client     *http.Client
+}
+
+// NewPagerDutyNotifier returns a Notifier triggering PagerDuty incidents
+// through the Events API v2 integration of routingKey, for the entries at
+// error level or above. The incidents are deduplicated by caller and message,
+// so that repeated errors are grouped into one incident.
+func NewPagerDutyNotifier(routingKey string) Notifier {
+	source, err := os.Hostname()
+	if err != nil || source == "" {
+		source = "zapx"
+	}
+	return &pagerDutyNotifier{url: pagerDutyEventsURL, routingKey: routingKey, source: source, client: http.DefaultClient}
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+// pagerDutyError is the error of a rejected event.
+type pagerDutyError struct {
+	status int
+	body   string
+}
+
+func (e *pagerDutyError) Error() string {
+	return fmt.Sprintf("pagerduty: %d %s", e.status, e.body)
+}
+
+// Retryable reports whether the event may be accepted if sent again.
+func (e *pagerDutyError) Retryable() bool {
+	return e.status == http.StatusTooManyRequests || e.status >= 500
+}
+
+// Notify is Notifier implementation.
+func (n *pagerDutyNotifier) Notify(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < zapcore.ErrorLevel {
+		return nil
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		if strings.HasPrefix(f.Key, "logging.googleapis.com/") || f.Key == "context" {
+			continue
+		}
+		f.AddTo(enc)
+	}
+	var component string
+	if svc, ok := enc.Fields["serviceContext"].(map[string]interface{}); ok {
+		component, _ = svc["service"].(string)
+		delete(enc.Fields, "serviceContext")
+	}
+	summary := ent.Message
+	if len(summary) > 1024 {
+		summary = summary[:1024]
+	}
+	ev := pagerDutyEvent{
+		RoutingKey:  n.routingKey,
+		EventAction: "trigger",
+		DedupKey:    pagerDutyDedupKey(ent),
+		Payload: pagerDutyPayload{
+			Summary:       summary,
+			Source:        n.source,
+			Severity:      pagerDutySeverity(ent.Level),
+			Timestamp:     ent.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+			Component:     component,
+			CustomDetails: enc.Fields,
+		},
+	}
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &pagerDutyError{status: resp.StatusCode, body: string(body)}
+	}
+	return nil
+}
+
+func pagerDutySeverity(l zapcore.Level) string {
+	if l >= zapcore.PanicLevel {
+		return "critical"
+	}
+	return "error"
+}
+
+// pagerDutyDedupKey derives the dedup key of an entry from its caller and
+// message.
+func pagerDutyDedupKey(ent zapcore.Entry) string {
+	h := sha1.New()
+	io.WriteString(h, ent.Caller.String())
+	io.WriteString(h, "\n")
+	io.WriteString(h, ent.Message)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,104 @@
+// Package promx exports the health of the zapx loggers and of their
+// notifications as Prometheus metrics.
+package promx
+
+import (
+	"time"
+
+	"github.com/lixin9311/zapx"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap/zapcore"
+)
+
+// Stats is a zapx.Stats implementation recording Prometheus metrics:
+//
+//	zapx_entries_total{level,logger}
+//	zapx_entries_dropped_total{level,reason}
+//	zapx_notifications_total{notifier,result}
+//	zapx_notification_retries_total{notifier}
+//	zapx_notifications_dropped_total{reason}
+//	zapx_notification_duration_seconds{notifier}
+type Stats struct {
+	entries              *prometheus.CounterVec
+	entriesDropped       *prometheus.CounterVec
+	notifications        *prometheus.CounterVec
+	notificationRetries  *prometheus.CounterVec
+	notificationsDropped *prometheus.CounterVec
+	notificationDuration *prometheus.HistogramVec
+}
+
+// New returns the metrics registered on reg, to be passed to zapx.WithStats.
+func New(reg prometheus.Registerer) (*Stats, error) {
+	s := &Stats{
+		entries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "zapx_entries_total",
+			Help: "Number of log entries written.",
+		}, []string{"level", "logger"}),
+		entriesDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "zapx_entries_dropped_total",
+			Help: "Number of log entries dropped by sampling, throttling or deduplication.",
+		}, []string{"level", "reason"}),
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "zapx_notifications_total",
+			Help: "Number of notifications delivered, by result.",
+		}, []string{"notifier", "result"}),
+		notificationRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "zapx_notification_retries_total",
+			Help: "Number of retries of the notifications.",
+		}, []string{"notifier"}),
+		notificationsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "zapx_notifications_dropped_total",
+			Help: "Number of notifications dropped before their delivery.",
+		}, []string{"reason"}),
+		notificationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "zapx_notification_duration_seconds",
+			Help:    "Duration of the delivery of the notifications, retries included.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+		}, []string{"notifier"}),
+	}
+	for _, c := range []prometheus.Collector{
+		s.entries,
+		s.entriesDropped,
+		s.notifications,
+		s.notificationRetries,
+		s.notificationsDropped,
+		s.notificationDuration,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// EntryWritten is zapx.Stats implementation.
+func (s *Stats) EntryWritten(level zapcore.Level, logger string) {
+	s.entries.WithLabelValues(level.String(), logger).Inc()
+}
+
+// EntryDropped is zapx.Stats implementation.
+func (s *Stats) EntryDropped(level zapcore.Level, reason string) {
+	s.entriesDropped.WithLabelValues(level.String(), reason).Inc()
+}
+
+// NotificationDelivered is zapx.Stats implementation.
+func (s *Stats) NotificationDelivered(notifier string, attempts int, latency time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	s.notifications.WithLabelValues(notifier, result).Inc()
+	if attempts > 1 {
+		s.notificationRetries.WithLabelValues(notifier).Add(float64(attempts - 1))
+	}
+	if attempts > 0 {
+		s.notificationDuration.WithLabelValues(notifier).Observe(latency.Seconds())
+	}
+}
+
+// NotificationDropped is zapx.Stats implementation.
+func (s *Stats) NotificationDropped(reason string) {
+	s.notificationsDropped.WithLabelValues(reason).Inc()
+}
+
+var _ zapx.Stats = (*Stats)(nil)
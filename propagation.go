@@ -0,0 +1,66 @@
+package zapx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// InjectHTTP sets the trace and request id found in ctx, see Context, on the
+// headers of an outgoing request, using both the x-cloud-trace-context and
+// the W3C traceparent formats.
+func InjectHTTP(ctx context.Context, h http.Header) {
+	for key, val := range propagationHeaders(contextInfoFrom(ctx), nil) {
+		h.Set(key, val)
+	}
+}
+
+// InjectGRPC returns a copy of ctx whose outgoing metadata carries the trace
+// and request id found in ctx, see InjectHTTP.
+func InjectGRPC(ctx context.Context) context.Context {
+	headers := propagationHeaders(contextInfoFrom(ctx), nil)
+	if len(headers) == 0 {
+		return ctx
+	}
+	kv := make([]string, 0, len(headers)*2)
+	for key, val := range headers {
+		kv = append(kv, key, val)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// propagationHeaders returns the headers of the trace and the request id of
+// info, the latter looked up and set under the request id keys, see
+// WithRequestIDKeys.
+func propagationHeaders(info contextInfo, keys []string) map[string]string {
+	keys = requestIDKeys(keys)
+	headers := make(map[string]string, 3)
+	if id := info.requestID(keys); id != "" {
+		headers[keys[0]] = id
+	}
+	if !isHex(info.TraceID, 32) || !isHex(info.SpanID, 16) {
+		return headers
+	}
+	spanID, _ := strconv.ParseUint(info.SpanID, 16, 64)
+	sampled, flags := "0", "00"
+	if info.IsSampled {
+		sampled, flags = "1", "01"
+	}
+	headers["x-cloud-trace-context"] = info.TraceID + "/" + strconv.FormatUint(spanID, 10) + ";o=" + sampled
+	headers["traceparent"] = "00-" + info.TraceID + "-" + info.SpanID + "-" + flags
+	return headers
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package zapx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ProtoResolver looks up the types used to expand google.protobuf.Any
+// messages logged by Proto.
+type ProtoResolver interface {
+	protoregistry.ExtensionTypeResolver
+	protoregistry.MessageTypeResolver
+}
+
+// jsonpbObjectMarshaler marshals a message to JSON lazily, when the entry is
+// encoded, and once for all the encoders of the entry, e.g. stdout and a
+// file, and all the entries of a logger when attached with With.
+type jsonpbObjectMarshaler struct {
+	pb       proto.Message
+	resolver ProtoResolver
+	maxBytes int
+	json     *protoJSON
+}
+
+// protoJSON is the JSON of a message, marshaled once.
+type protoJSON struct {
+	once sync.Once
+	buf  []byte
+	err  error
+}
+
+func newJSONPBObjectMarshaler(pb proto.Message, resolver ProtoResolver, maxBytes int) *jsonpbObjectMarshaler {
+	return &jsonpbObjectMarshaler{pb: pb, resolver: resolver, maxBytes: maxBytes, json: new(protoJSON)}
+}
+
+// ProtoMax constructs a field that carries val as Proto does, but replaces
+// its JSON by a summary with a truncated preview, within maxBytes, when it
+// exceeds maxBytes.
+func ProtoMax(key string, val proto.Message, maxBytes int) zapcore.Field {
+	return zap.Reflect(key, newJSONPBObjectMarshaler(val, nil, maxBytes))
+}
+
+// protoTruncated summarizes a message too large to be logged.
+type protoTruncated struct {
+	Type      string `json:"@type"`
+	Truncated bool   `json:"truncated"`
+	Size      int    `json:"size"`
+	Preview   string `json:"preview"`
+}
+
+func (j *jsonpbObjectMarshaler) MarshalJSON() ([]byte, error) {
+	j.json.once.Do(func() {
+		j.json.buf, j.json.err = j.marshal()
+	})
+	return j.json.buf, j.json.err
+}
+
+// marshal returns the JSON of the message. The buffers are not pooled: the
+// JSON is kept by protoJSON for every encoder of the field, and protojson
+// cannot marshal into a buffer of ours.
+func (j *jsonpbObjectMarshaler) marshal() ([]byte, error) {
+	opts := protomarshaler
+	if j.resolver != nil {
+		opts.Resolver = j.resolver
+	}
+	buf, err := opts.Marshal(j.pb)
+	if err != nil {
+		// Usually an Any whose type cannot be resolved, render it by hand.
+		if buf, err = json.Marshal(protoFallback(opts, j.pb.ProtoReflect())); err != nil {
+			return nil, err
+		}
+	}
+	if j.maxBytes > 0 && len(buf) > j.maxBytes {
+		return protoSummary(string(j.pb.ProtoReflect().Descriptor().FullName()), buf, j.maxBytes)
+	}
+	return buf, nil
+}
+
+// protoSummary returns the summary of buf, the JSON of a message of type typ
+// larger than maxBytes, within maxBytes unless even an empty preview does not
+// fit. The preview is cut at a rune boundary, short enough for its escaped
+// JSON to fit.
+func protoSummary(typ string, buf []byte, maxBytes int) ([]byte, error) {
+	sum := protoTruncated{Type: typ, Truncated: true, Size: len(buf)}
+	empty, err := json.Marshal(sum)
+	if err != nil || len(empty) >= maxBytes {
+		return empty, err
+	}
+	room := maxBytes - len(empty)
+	n := room
+	for {
+		for n > 0 && !utf8.RuneStart(buf[n]) {
+			n--
+		}
+		sum.Preview = string(buf[:n])
+		out, err := json.Marshal(sum)
+		if err != nil || len(out) <= maxBytes {
+			return out, err
+		}
+		// the n bytes of the preview take len(out)-len(empty) bytes escaped.
+		next := n * room / (len(out) - len(empty))
+		if next >= n {
+			next = n - 1
+		}
+		n = next
+	}
+}
+
+// protoFallback renders m field by field, falling back to the type URL and
+// the base64 encoded value for the Any messages that cannot be resolved.
+func protoFallback(opts protojson.MarshalOptions, m protoreflect.Message) interface{} {
+	if buf, err := opts.Marshal(m.Interface()); err == nil {
+		return json.RawMessage(buf)
+	}
+	if m.Descriptor().FullName() == "google.protobuf.Any" {
+		if a, ok := m.Interface().(*anypb.Any); ok {
+			return map[string]interface{}{
+				"@type": a.GetTypeUrl(),
+				"value": base64.StdEncoding.EncodeToString(a.GetValue()),
+			}
+		}
+	}
+	obj := make(map[string]interface{})
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		switch {
+		case fd.IsList():
+			list := v.List()
+			vals := make([]interface{}, list.Len())
+			for i := range vals {
+				vals[i] = protoFallbackValue(opts, fd, list.Get(i))
+			}
+			obj[name] = vals
+		case fd.IsMap():
+			vals := make(map[string]interface{})
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				vals[k.String()] = protoFallbackValue(opts, fd.MapValue(), v)
+				return true
+			})
+			obj[name] = vals
+		default:
+			obj[name] = protoFallbackValue(opts, fd, v)
+		}
+		return true
+	})
+	return obj
+}
+
+func protoFallbackValue(opts protojson.MarshalOptions, fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoFallback(opts, v.Message())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	default:
+		return v.Interface()
+	}
+}
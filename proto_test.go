@@ -0,0 +1,54 @@
+package zapx
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtoMax(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		maxBytes int
+		// fits reports whether the summary fits in maxBytes.
+		fits bool
+	}{
+		{"ascii", strings.Repeat("a", 500), 100, true},
+		{"multibyte", strings.Repeat("日本語", 100), 100, true},
+		{"escaped", strings.Repeat(`"<&>`, 100), 100, true},
+		{"no room for a preview", strings.Repeat("a", 500), 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newJSONPBObjectMarshaler(wrapperspb.String(tt.value), nil, tt.maxBytes)
+			full, err := newJSONPBObjectMarshaler(wrapperspb.String(tt.value), nil, 0).MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := m.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.fits && len(out) > tt.maxBytes {
+				t.Errorf("summary of %d bytes, want at most %d: %s", len(out), tt.maxBytes, out)
+			}
+			var sum protoTruncated
+			if err := json.Unmarshal(out, &sum); err != nil {
+				t.Fatalf("%v: %s", err, out)
+			}
+			if !sum.Truncated || sum.Size != len(full) || sum.Type != "google.protobuf.StringValue" {
+				t.Errorf("summary = %+v", sum)
+			}
+			if !utf8.ValidString(sum.Preview) || !strings.HasPrefix(string(full), sum.Preview) {
+				t.Errorf("preview %q is not a prefix of %s", sum.Preview, full)
+			}
+			if tt.fits && sum.Preview == "" {
+				t.Error("empty preview")
+			}
+		})
+	}
+}
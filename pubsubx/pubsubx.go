@@ -0,0 +1,100 @@
+// Package pubsubx publishes the zapx entries to a Pub/Sub topic, e.g. for a
+// Dataflow or BigQuery pipeline.
+package pubsubx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/lixin9311/zapx"
+)
+
+func init() {
+	zapx.RegisterSink("pubSub", func(c zapx.SinkConfig) (zapx.Option, error) {
+		if c["topic"] == "" {
+			return nil, errors.New("pubsubx: pubSub needs a topic")
+		}
+		return WithSink(c["project"], c["topic"]), nil
+	})
+}
+
+// WithSink also publishes the entries, as written to stdout, to the Pub/Sub
+// topic topicID of projectID, batched and asynchronously. The messages carry
+// the severity of the entry as attribute and its trace as ordering key. The
+// project defaults to the one of the logger, see zapx.WithProjectID. Sync
+// publishes the entries buffered. It is configured as the "pubSub" sink of
+// zapx.Config, with the "project" and "topic" keys.
+func WithSink(projectID, topicID string) zapx.Option {
+	return zapx.WithSink(func(env zapx.SinkEnv) (zapx.Sink, error) {
+		project := projectID
+		if project == "" {
+			project = env.Project
+		}
+		return newSink(project, topicID, env.OnError)
+	})
+}
+
+// sink is a zapx.Sink publishing the entries encoded by the core to a Pub/Sub
+// topic, batched and asynchronously. The entries of a trace share their
+// ordering key, so that the subscribers enabling message ordering receive
+// them in order.
+type sink struct {
+	client  *pubsub.Client
+	topic   *pubsub.Topic
+	onError func(error)
+}
+
+func newSink(projectID, topicID string, onError func(error)) (*sink, error) {
+	client, err := pubsub.NewClient(context.Background(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("pubsubx: failed to create the client: %w", err)
+	}
+	topic := client.Topic(topicID)
+	topic.EnableMessageOrdering = true
+	return &sink{client: client, topic: topic, onError: onError}, nil
+}
+
+// Write publishes the entry encoded in p, with its severity as attribute and
+// its trace as ordering key.
+func (s *sink) Write(p []byte) (int, error) {
+	var head struct {
+		Severity string `json:"severity"`
+		Trace    string `json:"logging.googleapis.com/trace"`
+	}
+	if err := json.Unmarshal(p, &head); err != nil {
+		return 0, err
+	}
+	msg := &pubsub.Message{
+		Data:        append([]byte(nil), p...),
+		OrderingKey: head.Trace,
+	}
+	if head.Severity != "" {
+		msg.Attributes = map[string]string{"severity": head.Severity}
+	}
+	res := s.topic.Publish(context.Background(), msg)
+	go func() {
+		if _, err := res.Get(context.Background()); err != nil {
+			s.onError(fmt.Errorf("pubsubx: failed to publish log entry: %w", err))
+			if msg.OrderingKey != "" {
+				// publishing is paused for the key after a failure.
+				s.topic.ResumePublish(msg.OrderingKey)
+			}
+		}
+	}()
+	return len(p), nil
+}
+
+// Sync publishes the entries buffered.
+func (s *sink) Sync() error {
+	s.topic.Flush()
+	return nil
+}
+
+// Close publishes the entries buffered and closes the client.
+func (s *sink) Close() error {
+	s.topic.Stop()
+	return s.client.Close()
+}
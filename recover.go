@@ -0,0 +1,96 @@
+package zapx
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Recover, deferred, recovers the panic of the goroutine and logs it at panic
+// level with the stack of the goroutine where it panicked, formatted for
+// Error Reporting, and fields. The entry is reported and notified like the
+// other entries, see WithSlackURL and WithNotifier, without the hook of
+// WithOnPanic, the goroutine then returning normally:
+//
+//	defer zapx.Recover(logger)
+func Recover(logger *zap.Logger, fields ...zapcore.Field) {
+	if r := recover(); r != nil {
+		logPanic(logger, r, true, fields...)
+	}
+}
+
+// RecoverRepanic is Recover, panicking again with the value recovered once it
+// is logged, the entry being then reported and notified before
+// RecoverRepanic returns, like the other panics.
+func RecoverRepanic(logger *zap.Logger, fields ...zapcore.Field) {
+	if r := recover(); r != nil {
+		logPanic(logger, r, false, fields...)
+		panic(r)
+	}
+}
+
+// logPanic logs the panic r at panic level, called by the function deferred
+// that recovered it, with the source location of the entry being where the
+// goroutine panicked. The entry is marked recovered unless the goroutine
+// panics again, the core then leaving out the termination of the process.
+func logPanic(logger *zap.Logger, r interface{}, recovered bool, fields ...zapcore.Field) {
+	skip, stack := panicSite()
+	msg := "panic: " + fmt.Sprint(r)
+	fields = append(fields[:len(fields):len(fields)],
+		zap.String("panic", fmt.Sprint(r)),
+		zap.String("stack_trace", panicStack(stack)),
+	)
+	if recovered {
+		fields = append(fields, zap.Bool(logKeyRecovered, true))
+	}
+	func() {
+		// zap panics with the message once the entry is written.
+		defer func() { recover() }()
+		// one more frame, this function.
+		logger.WithOptions(zap.AddCallerSkip(skip+1)).Panic(msg, fields...)
+	}()
+}
+
+// panicSite returns the frame where the goroutine panicked, counted from the
+// caller of panicSite, and the stack from there, in the format of the stack
+// traces of zap. The frames of the runtime raising the panic, e.g. for a nil
+// dereference, are skipped.
+func panicSite() (int, string) {
+	pcs := make([]uintptr, 64)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+	frames := runtime.CallersFrames(pcs)
+	site, panicking := -1, false
+	var b strings.Builder
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		switch {
+		case site >= 0:
+		case frame.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && !strings.HasPrefix(frame.Function, "runtime."):
+			site = i
+		}
+		if site >= 0 && frame.Function != "" {
+			if b.Len() != 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(frame.Function)
+			b.WriteString("\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	if site < 0 {
+		// not panicking, the caller then.
+		return 0, formatStack(pcs)
+	}
+	return site, b.String()
+}
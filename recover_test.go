@@ -0,0 +1,103 @@
+package zapx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(logger *zap.Logger)
+		// terminating is set for the panics propagated, notified and
+		// reported before the entry returns, with the hook of WithOnPanic.
+		terminating bool
+	}{
+		{
+			name: "Recover",
+			run: func(logger *zap.Logger) {
+				defer Recover(logger)
+				panic("boom")
+			},
+		},
+		{
+			name: "RecoverRepanic",
+			run: func(logger *zap.Logger) {
+				defer func() { recover() }()
+				defer RecoverRepanic(logger)
+				panic("boom")
+			},
+			terminating: true,
+		},
+		{
+			name: "RecoverMiddleware",
+			run: func(logger *zap.Logger) {
+				h := RecoverMiddleware(logger, false)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+					panic("boom")
+				}))
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			},
+		},
+		{
+			name: "RecoverMiddleware repanicking",
+			run: func(logger *zap.Logger) {
+				defer func() { recover() }()
+				h := RecoverMiddleware(logger, true)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+					panic("boom")
+				}))
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			},
+			terminating: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			var notified, hooked int64
+			n := NotifierFunc(func(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+				select {
+				case <-release:
+				case <-ctx.Done():
+				}
+				atomic.AddInt64(&notified, 1)
+				return nil
+			})
+			r := new(testReporter)
+			logger := Zap(zapcore.DebugLevel,
+				WithOutput(zapcore.AddSync(ioutil.Discard)),
+				WithNotifier(n),
+				WithSlackLevel(zapcore.ErrorLevel),
+				WithErrorReporter(func(SinkEnv) (ErrorReporter, error) { return r, nil }),
+				WithOnPanic(func(zapcore.Entry) { atomic.AddInt64(&hooked, 1) }),
+			)
+			if tt.terminating {
+				// delivered before the entry returns.
+				close(release)
+			}
+			tt.run(logger)
+			if got := atomic.LoadInt64(&notified); tt.terminating != (got == 1) {
+				t.Errorf("notified %d times once logged", got)
+			}
+			if !tt.terminating {
+				close(release)
+				eventually(t, func() bool { return atomic.LoadInt64(&notified) == 1 })
+			}
+			if got := atomic.LoadInt64(&hooked); tt.terminating != (got == 1) {
+				t.Errorf("hook called %d times", got)
+			}
+			if len(r.events) != 1 {
+				t.Fatalf("reported %d events, want 1", len(r.events))
+			}
+			if r.synced[0] != tt.terminating {
+				t.Errorf("reported synchronously = %v, want %v", r.synced[0], tt.terminating)
+			}
+		})
+	}
+}
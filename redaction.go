@@ -0,0 +1,302 @@
+package zapx
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redactedValue replaces the values redacted.
+const redactedValue = "[REDACTED]"
+
+// Patterns of personal data and secrets, see WithRedaction.
+var (
+	EmailPattern       = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	CreditCardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	BearerTokenPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+)
+
+// Sensitive constructs a field whose value is always masked, e.g. for a
+// password or a token, so that the value is never written nor notified.
+func Sensitive(key string, value interface{}) zapcore.Field {
+	return zap.String(key, redactedValue)
+}
+
+// redactor masks the values of the fields named after keys, and the parts of
+// the strings matching patterns, in the fields and their nested objects and
+// arrays.
+type redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(keys []string, patterns []*regexp.Regexp) *redactor {
+	if len(keys) == 0 && len(patterns) == 0 {
+		return nil
+	}
+	r := &redactor{keys: make(map[string]bool, len(keys)), patterns: patterns}
+	for _, key := range keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+	return r
+}
+
+func (r *redactor) sensitive(key string) bool {
+	return r.keys[strings.ToLower(key)]
+}
+
+func (r *redactor) string(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
+
+// fields returns fields redacted, fields itself if there is nothing to
+// redact.
+func (r *redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		rf, changed := r.field(f)
+		if changed && out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields[:i])
+		}
+		if out != nil {
+			out[i] = rf
+		}
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+func (r *redactor) field(f zapcore.Field) (zapcore.Field, bool) {
+	switch f.Type {
+	case zapcore.SkipType, zapcore.NamespaceType:
+		return f, false
+	}
+	if r.sensitive(f.Key) {
+		return zap.String(f.Key, redactedValue), true
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		if s := r.string(f.String); s != f.String {
+			return zap.String(f.Key, s), true
+		}
+	case zapcore.ByteStringType:
+		if s := r.string(string(f.Interface.([]byte))); s != string(f.Interface.([]byte)) {
+			return zap.String(f.Key, s), true
+		}
+	case zapcore.ErrorType, zapcore.StringerType:
+		// the message of the error is kept as a string if redacted.
+		var s string
+		if err, ok := f.Interface.(error); ok && f.Type == zapcore.ErrorType {
+			s = err.Error()
+		} else if v, ok := f.Interface.(fmt.Stringer); ok {
+			s = v.String()
+		}
+		if rs := r.string(s); rs != s {
+			return zap.String(f.Key, rs), true
+		}
+	case zapcore.ObjectMarshalerType:
+		switch m := f.Interface.(type) {
+		case labels:
+			// kept as labels for the matchers of the notifications.
+			return zap.Object(f.Key, labels(r.fields(m))), true
+		case multiError:
+			return zap.Object(f.Key, r.multiError(m)), true
+		}
+		return zap.Object(f.Key, redactedObject{m: f.Interface.(zapcore.ObjectMarshaler), r: r}), true
+	case zapcore.ArrayMarshalerType:
+		return zap.Array(f.Key, redactedArray{m: f.Interface.(zapcore.ArrayMarshaler), r: r}), true
+	case zapcore.ReflectType:
+		return zap.Reflect(f.Key, r.reflected(f.Interface)), true
+	}
+	return f, false
+}
+
+// multiError returns m with the messages of the errors redacted, and the
+// objects rendered by the error parser.
+func (r *redactor) multiError(m multiError) multiError {
+	errs := make([]error, len(m.errs))
+	for i, err := range m.errs {
+		errs[i] = redactedError{err: err, msg: r.string(err.Error())}
+	}
+	parse := m.parse
+	if parse != nil {
+		parse = func(err error) (zapcore.ObjectMarshaler, bool) {
+			if re, ok := err.(redactedError); ok {
+				err = re.err
+			}
+			obj, ok := m.parse(err)
+			if !ok {
+				return nil, false
+			}
+			return redactedObject{m: obj, r: r}, true
+		}
+	}
+	return multiError{err: redactedError{err: m.err, msg: r.string(m.err.Error())}, errs: errs, parse: parse}
+}
+
+// redactedError is an error whose message is redacted.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e redactedError) Error() string {
+	return e.msg
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// reflected returns v redacted, as a tree of JSON values.
+func (r *redactor) reflected(v interface{}) interface{} {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var tree interface{}
+	if err := json.Unmarshal(buf, &tree); err != nil {
+		return v
+	}
+	return r.tree(tree)
+}
+
+func (r *redactor) tree(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return r.string(v)
+	case map[string]interface{}:
+		for key, val := range v {
+			if r.sensitive(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = r.tree(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = r.tree(val)
+		}
+	}
+	return v
+}
+
+type redactedObject struct {
+	m zapcore.ObjectMarshaler
+	r *redactor
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (o redactedObject) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	return o.m.MarshalLogObject(redactingEncoder{ObjectEncoder: e, r: o.r})
+}
+
+// MarshalYAML implements yaml.Marshaler, used by slack notifications.
+func (o redactedObject) MarshalYAML() (interface{}, error) {
+	enc := zapcore.NewMapObjectEncoder()
+	err := o.MarshalLogObject(enc)
+	return enc.Fields, err
+}
+
+type redactedArray struct {
+	m zapcore.ArrayMarshaler
+	r *redactor
+}
+
+// MarshalLogArray is ArrayMarshaler implementation.
+func (a redactedArray) MarshalLogArray(e zapcore.ArrayEncoder) error {
+	return a.m.MarshalLogArray(redactingArrayEncoder{ArrayEncoder: e, r: a.r})
+}
+
+// MarshalYAML implements yaml.Marshaler, used by slack notifications.
+func (a redactedArray) MarshalYAML() (interface{}, error) {
+	enc := zapcore.NewMapObjectEncoder()
+	err := enc.AddArray("array", a)
+	return enc.Fields["array"], err
+}
+
+// redactingEncoder redacts the strings, objects, arrays and reflected values
+// added to the encoder it wraps.
+type redactingEncoder struct {
+	zapcore.ObjectEncoder
+	r *redactor
+}
+
+func (e redactingEncoder) AddString(key, value string) {
+	if e.r.sensitive(key) {
+		value = redactedValue
+	}
+	e.ObjectEncoder.AddString(key, e.r.string(value))
+}
+
+func (e redactingEncoder) AddByteString(key string, value []byte) {
+	e.AddString(key, string(value))
+}
+
+func (e redactingEncoder) AddBinary(key string, value []byte) {
+	if e.r.sensitive(key) {
+		e.ObjectEncoder.AddString(key, redactedValue)
+		return
+	}
+	e.ObjectEncoder.AddBinary(key, value)
+}
+
+func (e redactingEncoder) AddObject(key string, value zapcore.ObjectMarshaler) error {
+	if e.r.sensitive(key) {
+		e.ObjectEncoder.AddString(key, redactedValue)
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, redactedObject{m: value, r: e.r})
+}
+
+func (e redactingEncoder) AddArray(key string, value zapcore.ArrayMarshaler) error {
+	if e.r.sensitive(key) {
+		e.ObjectEncoder.AddString(key, redactedValue)
+		return nil
+	}
+	return e.ObjectEncoder.AddArray(key, redactedArray{m: value, r: e.r})
+}
+
+func (e redactingEncoder) AddReflected(key string, value interface{}) error {
+	if e.r.sensitive(key) {
+		e.ObjectEncoder.AddString(key, redactedValue)
+		return nil
+	}
+	return e.ObjectEncoder.AddReflected(key, e.r.reflected(value))
+}
+
+// redactingArrayEncoder is redactingEncoder for arrays.
+type redactingArrayEncoder struct {
+	zapcore.ArrayEncoder
+	r *redactor
+}
+
+func (e redactingArrayEncoder) AppendString(value string) {
+	e.ArrayEncoder.AppendString(e.r.string(value))
+}
+
+func (e redactingArrayEncoder) AppendByteString(value []byte) {
+	e.AppendString(string(value))
+}
+
+func (e redactingArrayEncoder) AppendObject(value zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactedObject{m: value, r: e.r})
+}
+
+func (e redactingArrayEncoder) AppendArray(value zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactedArray{m: value, r: e.r})
+}
+
+func (e redactingArrayEncoder) AppendReflected(value interface{}) error {
+	return e.ArrayEncoder.AppendReflected(e.r.reflected(value))
+}
@@ -0,0 +1,135 @@
+package zapx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// repeatMaxSites bounds the number of messages tracked by a repeatCore, the
+// others being written as is.
+const repeatMaxSites = 1024
+
+// repeatCore collapses the bursts of identical entries, see NewRepeatCore.
+type repeatCore struct {
+	zapcore.Core
+	r *repeater
+}
+
+// NewRepeatCore wraps core so that, out of the entries sharing their level,
+// caller and message, only the first threshold of every window are written.
+// The others are counted and summarized at the end of the window, or on Sync,
+// by an entry "previous message repeated 431 times in 10s" carrying the
+// message as "repeated_message" and the count as "repeated". Unlike WithDedup
+// the entries need not be consecutive, and unlike the notification dedup the
+// entries themselves are dropped, before being encoded. The entries at or
+// above DPanic are always written.
+func NewRepeatCore(core zapcore.Core, window time.Duration, threshold int) zapcore.Core {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &repeatCore{Core: core, r: &repeater{window: window, threshold: threshold, sites: make(map[string]*repeatSite)}}
+}
+
+func (c *repeatCore) With(fields []zapcore.Field) zapcore.Core {
+	return &repeatCore{Core: c.Core.With(fields), r: c.r}
+}
+
+func (c *repeatCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) || !c.r.allow(c.Core, ent) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c *repeatCore) Sync() error {
+	c.r.flush()
+	return c.Core.Sync()
+}
+
+// repeatSite counts the entries of a key within a window.
+type repeatSite struct {
+	ent   zapcore.Entry
+	first time.Time
+	count int
+	// dropped is the number of entries dropped, and core the core of the
+	// last of them, which writes the summary.
+	dropped int
+	core    zapcore.Core
+	timer   *time.Timer
+}
+
+// repeater keeps track of the entries of a repeatCore and all of its
+// children.
+type repeater struct {
+	window    time.Duration
+	threshold int
+
+	mu    sync.Mutex
+	sites map[string]*repeatSite
+}
+
+// allow reports whether ent, checked by core, may be written.
+func (r *repeater) allow(core zapcore.Core, ent zapcore.Entry) bool {
+	if ent.Level >= zapcore.DPanicLevel {
+		return true
+	}
+	key := dedupKey(ent)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	site, ok := r.sites[key]
+	if !ok {
+		if len(r.sites) >= repeatMaxSites {
+			return true
+		}
+		site = &repeatSite{ent: ent, first: ent.Time}
+		site.timer = time.AfterFunc(r.window, func() { r.release(key, site) })
+		r.sites[key] = site
+	}
+	site.count++
+	if site.count <= r.threshold {
+		return true
+	}
+	site.dropped++
+	site.core = core
+	return false
+}
+
+// release ends the window of site, writing its summary.
+func (r *repeater) release(key string, site *repeatSite) {
+	r.mu.Lock()
+	if r.sites[key] != site {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.sites, key)
+	r.mu.Unlock()
+	r.summarize(site)
+}
+
+// flush ends all the windows, writing their summaries.
+func (r *repeater) flush() {
+	r.mu.Lock()
+	sites := r.sites
+	r.sites = make(map[string]*repeatSite)
+	r.mu.Unlock()
+	for _, site := range sites {
+		site.timer.Stop()
+		r.summarize(site)
+	}
+}
+
+func (r *repeater) summarize(site *repeatSite) {
+	if site.dropped == 0 {
+		return
+	}
+	ent := site.ent
+	ent.Time = time.Now()
+	ent.Message = fmt.Sprintf("previous message repeated %d times in %s", site.dropped, r.window)
+	if ce := site.core.Check(ent, nil); ce != nil {
+		ce.Write(zap.String("repeated_message", site.ent.Message), zap.Int("repeated", site.dropped))
+	}
+}
@@ -0,0 +1,53 @@
+package zapx
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type userContextKey struct{}
+
+// ContextWithUser returns a copy of ctx carrying the user reported by the
+// loggers returned by ForRequest.
+func ContextWithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the user set by ContextWithUser.
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userContextKey{}).(string)
+	return user
+}
+
+// ForRequest returns a child logger carrying the trace of ctx, see Context,
+// the request id as the "request_id" label and the user set by
+// ContextWithUser, so that all the entries of a request are correlated. A
+// Context among fields replaces the one of ctx.
+func ForRequest(logger *zap.Logger, ctx context.Context, fields ...zapcore.Field) *zap.Logger {
+	info := contextInfoFrom(ctx)
+	rest := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		if i, ok := f.Interface.(contextInfo); ok && f.Key == logKeyContextInfo {
+			info = i
+			continue
+		}
+		rest = append(rest, f)
+	}
+	var keys []string
+	if s, ok := coreOf(logger); ok {
+		keys = s.requestIDKeys
+	}
+	fs := make([]zapcore.Field, 0, len(rest)+3)
+	if id := info.requestID(requestIDKeys(keys)); id != "" {
+		fs = append(fs, Label("request_id", id))
+		info.requestIDLabel = true
+	}
+	fs = append(fs, zap.Reflect(logKeyContextInfo, info))
+	if user := UserFromContext(ctx); user != "" {
+		fs = append(fs, zap.String("user", user))
+	}
+	fs = append(fs, rest...)
+	return logger.With(fs...)
+}
@@ -0,0 +1,55 @@
+package zapx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request id picked
+// up by Context.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDMiddleware returns a middleware making sure every request has a
+// request id: it takes the one of the first request id header of logger found,
+// see WithRequestIDKeys, or generates one, echoes it in the response header so
+// that error pages can display it, and stores it in the request context for
+// Context.
+func RequestIDMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	var keys []string
+	if s, ok := coreOf(logger); ok {
+		keys = s.requestIDKeys
+	}
+	keys = requestIDKeys(keys)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, id := keys[0], ""
+			for _, k := range keys {
+				if id = r.Header.Get(k); id != "" {
+					key = k
+					break
+				}
+			}
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(key, id)
+			next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,48 @@
+package zapx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestForRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		opts []Option
+		want string
+	}{
+		{"none", context.Background(), nil, ""},
+		{"context", ContextWithRequestID(context.Background(), "req-1"), nil, "req-1"},
+		{"metadata", metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-2")), nil, "req-2"},
+		{
+			"metadata with custom keys",
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-correlation-id", "req-3")),
+			[]Option{WithRequestIDKeys("x-correlation-id")},
+			"req-3",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := Zap(zapcore.DebugLevel, append(tt.opts, WithOutput(zapcore.AddSync(&buf)))...)
+		ForRequest(logger, tt.ctx).Info("hello")
+		var entry struct {
+			Labels    map[string]string `json:"logging.googleapis.com/labels"`
+			RequestID *string           `json:"request_id"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: %v: %s", tt.name, err, buf.Bytes())
+		}
+		if got := entry.Labels["request_id"]; got != tt.want {
+			t.Errorf("%s: request_id label = %q, want %q", tt.name, got, tt.want)
+		}
+		if entry.RequestID != nil {
+			t.Errorf("%s: request_id field %q emitted along with the label", tt.name, *entry.RequestID)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package zapx
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// monitoredResource is the monitored resource the entries are written by.
+// See https://cloud.google.com/logging/docs/api/v2/resource-list
+type monitoredResource struct {
+	Type   string
+	Labels map[string]string
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (r *monitoredResource) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("type", r.Type)
+	return e.AddObject("labels", stringMap(r.Labels))
+}
+
+type stringMap map[string]string
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (m stringMap) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	for key, val := range m {
+		e.AddString(key, val)
+	}
+	return nil
+}
+
+// detectProjectID returns the project id set in the environment, by the
+// Google Cloud runtimes or the user, if any.
+func detectProjectID() string {
+	for _, env := range []string{"GOOGLE_CLOUD_PROJECT", "GCP_PROJECT", "GCLOUD_PROJECT"} {
+		if id := os.Getenv(env); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// detectResource detects the monitored resource of the runtime environment:
+// a Cloud Function, a Cloud Run revision, a GKE container or a GCE instance,
+// falling back to global.
+func detectResource(ctx context.Context, projectID string) *monitoredResource {
+	md := newMetadataClient()
+	if projectID == "" {
+		projectID = md.get(ctx, "project/project-id")
+	}
+	switch {
+	case os.Getenv("FUNCTION_NAME") != "":
+		// 1st gen functions.
+		return &monitoredResource{
+			Type: "cloud_function",
+			Labels: map[string]string{
+				"project_id":    projectID,
+				"function_name": os.Getenv("FUNCTION_NAME"),
+				"region":        os.Getenv("FUNCTION_REGION"),
+			},
+		}
+	case os.Getenv("FUNCTION_TARGET") != "" && os.Getenv("K_SERVICE") != "":
+		// 2nd gen functions run on Cloud Run, but log as functions.
+		return &monitoredResource{
+			Type: "cloud_function",
+			Labels: map[string]string{
+				"project_id":    projectID,
+				"function_name": os.Getenv("K_SERVICE"),
+				"region":        lastSegment(md.get(ctx, "instance/region")),
+			},
+		}
+	case os.Getenv("K_SERVICE") != "" && os.Getenv("K_REVISION") != "":
+		return &monitoredResource{
+			Type: "cloud_run_revision",
+			Labels: map[string]string{
+				"project_id":         projectID,
+				"service_name":       os.Getenv("K_SERVICE"),
+				"revision_name":      os.Getenv("K_REVISION"),
+				"configuration_name": os.Getenv("K_CONFIGURATION"),
+				"location":           lastSegment(md.get(ctx, "instance/region")),
+			},
+		}
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		namespace := os.Getenv("NAMESPACE")
+		if namespace == "" {
+			if buf, err := ioutil.ReadFile(k8sNamespaceFile); err == nil {
+				namespace = strings.TrimSpace(string(buf))
+			}
+		}
+		hostname, _ := os.Hostname()
+		return &monitoredResource{
+			Type: "k8s_container",
+			Labels: map[string]string{
+				"project_id":     projectID,
+				"location":       md.get(ctx, "instance/attributes/cluster-location"),
+				"cluster_name":   md.get(ctx, "instance/attributes/cluster-name"),
+				"namespace_name": namespace,
+				"pod_name":       hostname,
+				"container_name": os.Getenv("CONTAINER_NAME"),
+			},
+		}
+	}
+	if id := md.get(ctx, "instance/id"); id != "" {
+		return &monitoredResource{
+			Type: "gce_instance",
+			Labels: map[string]string{
+				"project_id":  projectID,
+				"instance_id": id,
+				"zone":        lastSegment(md.get(ctx, "instance/zone")),
+			},
+		}
+	}
+	return &monitoredResource{
+		Type:   "global",
+		Labels: map[string]string{"project_id": projectID},
+	}
+}
+
+// environment is the deployment the logger runs in, see WithAutoDetect.
+type environment struct {
+	projectID string
+	service   string
+	version   string
+	region    string
+	zone      string
+}
+
+// detectEnvironment detects the deployment from the environment variables
+// set by Cloud Run, Cloud Functions and App Engine, and from the metadata
+// server.
+func detectEnvironment(ctx context.Context) environment {
+	var env environment
+	for _, vars := range [][2]string{
+		{"K_SERVICE", "K_REVISION"},
+		{"GAE_SERVICE", "GAE_VERSION"},
+		{"FUNCTION_NAME", "X_GOOGLE_FUNCTION_VERSION"},
+	} {
+		if service := os.Getenv(vars[0]); service != "" {
+			env.service, env.version = service, os.Getenv(vars[1])
+			break
+		}
+	}
+	md := newMetadataClient()
+	env.projectID = detectProjectID()
+	if env.projectID == "" {
+		env.projectID = md.get(ctx, "project/project-id")
+	}
+	env.zone = lastSegment(md.get(ctx, "instance/zone"))
+	env.region = lastSegment(md.get(ctx, "instance/region"))
+	if env.region == "" {
+		env.region = os.Getenv("FUNCTION_REGION")
+	}
+	if env.region == "" && strings.Count(env.zone, "-") == 2 {
+		// us-central1-a is in us-central1.
+		env.region = env.zone[:strings.LastIndex(env.zone, "-")]
+	}
+	return env
+}
+
+// labels returns the region and the zone of the deployment as labels.
+func (e environment) labels() labels {
+	var lbs labels
+	if e.region != "" {
+		lbs = append(lbs, zap.String("region", e.region))
+	}
+	if e.zone != "" {
+		lbs = append(lbs, zap.String("zone", e.zone))
+	}
+	return lbs
+}
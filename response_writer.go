@@ -0,0 +1,112 @@
+package zapx
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter is an http.ResponseWriter recording the status, the size and
+// the latency of a response, see WrapResponseWriter.
+type ResponseWriter struct {
+	w         http.ResponseWriter
+	start     time.Time
+	status    int
+	size      int64
+	firstByte time.Duration
+}
+
+// WrapResponseWriter returns a ResponseWriter writing to w, the latencies
+// being measured from now.
+func WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{w: w, start: time.Now()}
+}
+
+// Header is http.ResponseWriter implementation.
+func (w *ResponseWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+// WriteHeader is http.ResponseWriter implementation.
+func (w *ResponseWriter) WriteHeader(status int) {
+	w.begin(status)
+	w.w.WriteHeader(status)
+}
+
+// Write is http.ResponseWriter implementation.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	w.begin(http.StatusOK)
+	n, err := w.w.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.w.(http.Flusher); ok {
+		w.begin(http.StatusOK)
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer does.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("zapx: response writer does not implement http.Hijacker")
+	}
+	w.begin(http.StatusSwitchingProtocols)
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.w
+}
+
+func (w *ResponseWriter) begin(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.firstByte = time.Since(w.start)
+	}
+}
+
+// Status returns the status of the response, 200 if the handler wrote
+// nothing.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of bytes of the body written so far.
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// FirstByte returns the latency of the headers of the response, or zero if
+// they are not written yet.
+func (w *ResponseWriter) FirstByte() time.Duration {
+	return w.firstByte
+}
+
+// Entry returns the HTTPRequestEntry of the response to r, its latency being
+// the time elapsed since the writer was wrapped.
+func (w *ResponseWriter) Entry(r *http.Request) HTTPRequestEntry {
+	e := HTTPRequestEntry{
+		Request:      r,
+		Status:       w.Status(),
+		ResponseSize: w.size,
+		Latency:      time.Since(w.start),
+	}
+	if r != nil {
+		e.RequestMethod = r.Method
+		if r.ContentLength > 0 {
+			e.RequestSize = r.ContentLength
+		}
+	}
+	return e
+}
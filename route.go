@@ -0,0 +1,42 @@
+package zapx
+
+import (
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// router writes the entries to the core matching the value of a field.
+type router struct {
+	key   string
+	cores map[string]zapcore.Core
+}
+
+func newRouter(key string, routes map[string]zapcore.WriteSyncer, enc zapcore.Encoder, enab zapcore.LevelEnabler) *router {
+	r := &router{key: key, cores: make(map[string]zapcore.Core, len(routes))}
+	for val, ws := range routes {
+		r.cores[val] = zapcore.NewCore(enc.Clone(), ws, enab)
+	}
+	return r
+}
+
+// route returns the core of the first field matching the routing key.
+func (r *router) route(fields ...[]zapcore.Field) (zapcore.Core, bool) {
+	for _, fs := range fields {
+		for _, f := range fs {
+			if f.Key != r.key || f.Type != zapcore.StringType {
+				continue
+			}
+			core, ok := r.cores[f.String]
+			return core, ok
+		}
+	}
+	return nil, false
+}
+
+func (r *router) sync() error {
+	var err error
+	for _, core := range r.cores {
+		err = multierr.Append(err, core.Sync())
+	}
+	return err
+}
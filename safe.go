@@ -0,0 +1,139 @@
+package zapx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const safeMaxDepth = 10
+
+// Safe constructs a field that carries a best-effort dump of v. Unlike
+// zap.Reflect it never fails: cycles, values nested deeper than 10 levels and
+// values that cannot be encoded are replaced by a short placeholder.
+func Safe(key string, v interface{}) zapcore.Field {
+	return zap.Reflect(key, safeValue{v: v})
+}
+
+type safeValue struct {
+	v interface{}
+}
+
+func (s safeValue) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(safeDump(reflect.ValueOf(s.v), 0, make(map[uintptr]bool))); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// MarshalYAML implements yaml.Marshaler, used by slack notifications.
+func (s safeValue) MarshalYAML() (interface{}, error) {
+	return safeDump(reflect.ValueOf(s.v), 0, make(map[uintptr]bool)), nil
+}
+
+// safeDump converts v into a tree of values that encoding/json always
+// accepts.
+func safeDump(v reflect.Value, depth int, visited map[uintptr]bool) (out interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			out = fmt.Sprintf("<panic: %v>", r)
+		}
+	}()
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > safeMaxDepth {
+		return "<max depth>"
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case error:
+			if v.Kind() != reflect.Ptr || !v.IsNil() {
+				return x.Error()
+			}
+		case fmt.Stringer:
+			if v.Kind() != reflect.Ptr || !v.IsNil() {
+				return x.String()
+			}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			if visited[v.Pointer()] {
+				return "<cycle>"
+			}
+			visited[v.Pointer()] = true
+			defer delete(visited, v.Pointer())
+		}
+		return safeDump(v.Elem(), depth+1, visited)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if visited[v.Pointer()] {
+			return "<cycle>"
+		}
+		visited[v.Pointer()] = true
+		defer delete(visited, v.Pointer())
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(safeDump(iter.Key(), depth+1, visited))] = safeDump(iter.Value(), depth+1, visited)
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes()
+		}
+		fallthrough
+	case reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = safeDump(v.Index(i), depth+1, visited)
+		}
+		return s
+	case reflect.Struct:
+		m := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			m[t.Field(i).Name] = safeDump(v.Field(i), depth+1, visited)
+		}
+		return m
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Sprint(f)
+		}
+		return v.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(v.Complex())
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	default:
+		// chan, func and unsafe.Pointer
+		return "<" + v.Type().String() + ">"
+	}
+}
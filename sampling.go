@@ -0,0 +1,80 @@
+package zapx
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelSampler keeps one out of every n entries of each level, and counts
+// the entries it drops until they are reported.
+type levelSampler struct {
+	every    map[zapcore.Level]uint64
+	interval time.Duration
+
+	mu         sync.Mutex
+	seen       map[zapcore.Level]uint64
+	dropped    map[zapcore.Level]uint64
+	lastReport time.Time
+}
+
+func newLevelSampler(rates map[zapcore.Level]float64, interval time.Duration) *levelSampler {
+	s := &levelSampler{
+		every:      make(map[zapcore.Level]uint64, len(rates)),
+		interval:   interval,
+		seen:       make(map[zapcore.Level]uint64),
+		dropped:    make(map[zapcore.Level]uint64),
+		lastReport: time.Now(),
+	}
+	for lvl, rate := range rates {
+		switch {
+		case rate >= 1:
+			s.every[lvl] = 1
+		case rate <= 0:
+			s.every[lvl] = 0
+		default:
+			s.every[lvl] = uint64(1/rate + 0.5)
+		}
+	}
+	return s
+}
+
+// sample reports whether the entry of the level should be kept.
+func (s *levelSampler) sample(lvl zapcore.Level) bool {
+	every, ok := s.every[lvl]
+	if !ok || every == 1 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.seen[lvl]
+	s.seen[lvl] = n + 1
+	if every != 0 && n%every == 0 {
+		return true
+	}
+	s.dropped[lvl]++
+	return false
+}
+
+// report returns the entry reporting the dropped entries, if the interval has
+// elapsed and entries were dropped since the last report.
+func (s *levelSampler) report(now time.Time) (zapcore.Entry, []zapcore.Field, bool) {
+	if s.interval <= 0 {
+		return zapcore.Entry{}, nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastReport) < s.interval || len(s.dropped) == 0 {
+		return zapcore.Entry{}, nil, false
+	}
+	fs := make([]zapcore.Field, 0, len(s.dropped))
+	for lvl, n := range s.dropped {
+		fs = append(fs, zap.Uint64(lvl.String(), n))
+	}
+	s.dropped = make(map[zapcore.Level]uint64)
+	s.lastReport = now
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: now, Message: "zapx: entries dropped by sampling"}
+	return ent, []zapcore.Field{zap.Object("sampled", labels(fs))}, true
+}
@@ -0,0 +1,112 @@
+package zapx
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Sink receives the entries as encoded by the core, e.g. to send them to a
+// logging backend, see WithSink. It must be safe for concurrent use. Close
+// flushes the entries buffered and releases the sink, see Close.
+type Sink interface {
+	zapcore.WriteSyncer
+	Close() error
+}
+
+// SinkEnv is the environment of the logger passed to the builders of its
+// sinks.
+type SinkEnv struct {
+	// Project is the project of the logger, see WithProjectID, detected if
+	// unset.
+	Project string
+	// ServiceContext is the service and the version of the logger.
+	ServiceContext ServiceContext
+	// OnError reports the failures of the sink, e.g. the entries that could
+	// not be delivered, see WithInternalErrorHandler.
+	OnError func(error)
+}
+
+// SinkBuilder builds a sink when the logger is created by Zap.
+type SinkBuilder func(env SinkEnv) (Sink, error)
+
+type sinkOption struct {
+	build SinkBuilder
+	// output writes the entries to the sink instead of the output.
+	output bool
+}
+
+// WithSink also writes the entries, as written to stdout, to the sink built
+// by build, e.g. by a subpackage such as pubsubx. Sync syncs the sink, and
+// Close closes it.
+func WithSink(build SinkBuilder) Option {
+	return func(o *option) {
+		o.sinks = append(o.sinks, sinkOption{build: build})
+	}
+}
+
+// WithOutputSink writes the entries to the sink built by build instead of
+// stdout, e.g. by cloudloggingx where no logging agent collects stdout. The
+// logger falls back to stdout if the sink cannot be built.
+func WithOutputSink(build SinkBuilder) Option {
+	return func(o *option) {
+		o.sinks = append(o.sinks, sinkOption{build: build, output: true})
+	}
+}
+
+// sinkEnv returns the environment of the sinks of the logger.
+func (o *option) sinkEnv() SinkEnv {
+	return SinkEnv{
+		Project:        o.project(""),
+		ServiceContext: ServiceContext{Service: o.service, Version: o.version},
+		OnError:        o.onError.handle,
+	}
+}
+
+// SinkConfig is the configuration of a sink registered with RegisterSink,
+// e.g. {"project": "p", "topic": "logs"}.
+type SinkConfig map[string]string
+
+var sinkRegistry = struct {
+	sync.RWMutex
+	options map[string]func(SinkConfig) (Option, error)
+}{options: make(map[string]func(SinkConfig) (Option, error))}
+
+// RegisterSink makes the sink, or the error reporter, name configurable in
+// the Sinks of Config, options returning the options of its configuration.
+// It is called by the subpackages of the sinks when imported, e.g.
+// cloudloggingx, and panics if name is registered twice.
+func RegisterSink(name string, options func(SinkConfig) (Option, error)) {
+	sinkRegistry.Lock()
+	defer sinkRegistry.Unlock()
+	if _, dup := sinkRegistry.options[name]; dup {
+		panic("zapx: RegisterSink called twice for sink " + name)
+	}
+	sinkRegistry.options[name] = options
+}
+
+// sinkOptions returns the options of the sinks configured.
+func sinkOptions(sinks map[string]SinkConfig) ([]Option, error) {
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sinkRegistry.RLock()
+	defer sinkRegistry.RUnlock()
+	opts := make([]Option, 0, len(names))
+	for _, name := range names {
+		options, ok := sinkRegistry.options[name]
+		if !ok {
+			return nil, fmt.Errorf("zapx: unknown sink %q, forgotten import?", name)
+		}
+		opt, err := options(sinks[name])
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
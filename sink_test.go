@@ -0,0 +1,118 @@
+package zapx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// testSink is a Sink recording the entries written.
+type testSink struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (s *testSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *testSink) Sync() error { return nil }
+
+func (s *testSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *testSink) lines() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Count(s.buf.String(), "\n")
+}
+
+func TestSinks(t *testing.T) {
+	errBuild := errors.New("unavailable")
+	tests := []struct {
+		name string
+		// option returns the option of the sink, built into sink unless
+		// broken.
+		option     func(build SinkBuilder) Option
+		broken     bool
+		wantSink   int
+		wantOutput int
+	}{
+		{"sink", WithSink, false, 1, 1},
+		{"output sink", WithOutputSink, false, 1, 0},
+		{"broken sink", WithSink, true, 0, 1},
+		{"broken output sink falls back to the output", WithOutputSink, true, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := new(testSink)
+			var env SinkEnv
+			build := func(e SinkEnv) (Sink, error) {
+				env = e
+				if tt.broken {
+					return nil, errBuild
+				}
+				return sink, nil
+			}
+			var out bytes.Buffer
+			var errs []error
+			logger := Zap(zapcore.DebugLevel,
+				WithOutput(zapcore.AddSync(&out)),
+				WithProjectID("p"),
+				WithService("svc"),
+				WithInternalErrorHandler(func(err error) { errs = append(errs, err) }),
+				tt.option(build),
+			)
+			logger.Info("hello")
+			if err := Close(context.Background(), logger); err != nil {
+				t.Fatal(err)
+			}
+			if env.Project != "p" || env.ServiceContext.Service != "svc" {
+				t.Errorf("env = %+v", env)
+			}
+			if got := sink.lines(); got != tt.wantSink {
+				t.Errorf("sink got %d entries, want %d", got, tt.wantSink)
+			}
+			if got := strings.Count(out.String(), "\n"); got != tt.wantOutput {
+				t.Errorf("output got %d entries, want %d", got, tt.wantOutput)
+			}
+			if sink.closed == tt.broken {
+				t.Errorf("sink closed = %v", sink.closed)
+			}
+			if tt.broken && (len(errs) != 1 || !errors.Is(errs[0], errBuild)) {
+				t.Errorf("errors = %v, want %v", errs, errBuild)
+			}
+		})
+	}
+}
+
+func TestConfigSinks(t *testing.T) {
+	var got SinkConfig
+	RegisterSink("test", func(c SinkConfig) (Option, error) {
+		got = c
+		return WithSink(func(SinkEnv) (Sink, error) { return new(testSink), nil }), nil
+	})
+	c := Config{Sinks: map[string]SinkConfig{"test": {"topic": "logs"}}}
+	if _, err := c.Options(); err != nil {
+		t.Fatal(err)
+	}
+	if got["topic"] != "logs" {
+		t.Errorf("config = %v", got)
+	}
+	c.Sinks["unknown"] = nil
+	if _, err := c.Options(); err == nil {
+		t.Error("unknown sink configured")
+	}
+}
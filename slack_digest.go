@@ -0,0 +1,132 @@
+package zapx
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap/zapcore"
+)
+
+// slackDigest aggregates the Slack notifications of a logger and all of its
+// children over an interval, and posts them as a single message per webhook
+// listing the entries grouped by level.
+type slackDigest struct {
+	s        *stackdriver
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string][]zapcore.Entry // by destination
+	timer   *time.Timer
+}
+
+func newSlackDigest(s *stackdriver, interval time.Duration) *slackDigest {
+	return &slackDigest{s: s, interval: interval, entries: make(map[string][]zapcore.Entry)}
+}
+
+// slackDigestTarget is the Notifier adding the entries to the digest of a
+// webhook.
+type slackDigestTarget struct {
+	d   *slackDigest
+	url string
+}
+
+// Notify is Notifier implementation.
+func (t slackDigestTarget) Notify(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+	if _, ok := levelColorMap[ent.Level]; !ok {
+		return nil
+	}
+	d := t.d
+	if ent.Level >= zapcore.PanicLevel {
+		// the process is about to terminate, don't wait for the digest.
+		return d.s.slackNotifier(t.url).Notify(ctx, ent, fields)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[t.url] = append(d.entries[t.url], ent)
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.interval, d.flush)
+	}
+	return nil
+}
+
+// flush queues the digests of the entries added so far.
+func (d *slackDigest) flush() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	entries := d.entries
+	d.entries = make(map[string][]zapcore.Entry)
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.mu.Unlock()
+	for url, ents := range entries {
+		ents := ents // captured by the job
+		n := d.s.slackNotifier(url)
+		post := NotifierFunc(func(ctx context.Context, _ zapcore.Entry, _ []zapcore.Field) error {
+			return n.postDigest(ctx, ents, d.interval)
+		})
+		d.s.notifyPool.enqueue(notifyJob{targets: []Notifier{post}, ent: ents[len(ents)-1]})
+	}
+}
+
+// slackDigestLevels are the levels of a digest, most severe first.
+var slackDigestLevels = []zapcore.Level{
+	zapcore.FatalLevel, zapcore.PanicLevel, zapcore.DPanicLevel,
+	zapcore.ErrorLevel, zapcore.WarnLevel, zapcore.InfoLevel, zapcore.DebugLevel,
+}
+
+func (n *slackNotifier) postDigest(ctx context.Context, ents []zapcore.Entry, interval time.Duration) error {
+	byLevel := make(map[zapcore.Level][]zapcore.Entry)
+	for _, ent := range ents {
+		byLevel[ent.Level] = append(byLevel[ent.Level], ent)
+	}
+	head := slack.SectionBlock{
+		Type: slack.MBTSection,
+		Text: &slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("*%d notifications in the last %s*", len(ents), interval),
+		},
+		Fields: []*slack.TextBlockObject{
+			{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", "Service", n.svcCtx.Service)},
+			{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", "Version", n.svcCtx.Version)},
+		},
+	}
+	var attachments []slack.Attachment
+	for _, level := range slackDigestLevels {
+		lents := byLevel[level]
+		if len(lents) == 0 {
+			continue
+		}
+		sort.SliceStable(lents, func(i, j int) bool { return lents[i].Time.Before(lents[j].Time) })
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%s* (%d)\n", level.CapitalString(), len(lents))
+		for _, ent := range lents {
+			line := fmt.Sprintf("• `%s` %s _%s_\n", ent.Time.In(n.loc).Format(time.TimeOnly), ent.Message, ent.Caller.TrimmedPath())
+			// a text block holds up to 3000 characters
+			if b.Len()+len(line) > 2900 {
+				b.WriteString("…")
+				break
+			}
+			b.WriteString(line)
+		}
+		attachments = append(attachments, slack.Attachment{
+			Color: levelColorMap[level],
+			Blocks: slack.Blocks{BlockSet: []slack.Block{
+				slack.NewSectionBlock(&slack.TextBlockObject{Type: "mrkdwn", Text: b.String()}, nil, nil),
+			}},
+		})
+	}
+	payload := &slack.WebhookMessage{
+		Blocks:      &slack.Blocks{BlockSet: []slack.Block{head}},
+		Attachments: attachments,
+	}
+	return n.post(ctx, payload)
+}
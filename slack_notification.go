@@ -6,22 +6,24 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/lixin9311/backoff/v2"
 	"github.com/slack-go/slack"
 	"go.uber.org/zap/zapcore"
-	"google.golang.org/grpc/grpclog"
 	"gopkg.in/yaml.v2"
 )
 
 var levelColorMap = map[zapcore.Level]string{
-	zapcore.DebugLevel: "#2196F3",
-	zapcore.InfoLevel:  "#9E9E9E",
-	zapcore.WarnLevel:  "#FF9800",
-	zapcore.ErrorLevel: "#D50000",
-	zapcore.FatalLevel: "#D50000",
-	zapcore.PanicLevel: "#D50000",
+	zapcore.DebugLevel:  "#2196F3",
+	zapcore.InfoLevel:   "#9E9E9E",
+	zapcore.WarnLevel:   "#FF9800",
+	zapcore.ErrorLevel:  "#D50000",
+	zapcore.DPanicLevel: "#D50000",
+	zapcore.FatalLevel:  "#D50000",
+	zapcore.PanicLevel:  "#D50000",
 }
 
 type retryableError interface {
@@ -42,7 +44,6 @@ func (r *slackRetrier) Retry(n int, err error) (time.Duration, bool) {
 		return rateErr.RetryAfter, true
 	} else if rerr, ok := err.(retryableError); ok {
 		if !rerr.Retryable() {
-			grpclog.Errorf("zapx: failed to post slack notification: %v", err)
 			return 0, false
 		}
 	} // else retry
@@ -50,20 +51,134 @@ func (r *slackRetrier) Retry(n int, err error) (time.Duration, bool) {
 	return dur, true
 }
 
-var defaultRetrier = &slackRetrier{max: 10}
+func newSlackRetrier(max int, bo backoff.Backoff) *slackRetrier {
+	// Backoff fills in the zero values on first use, which the workers
+	// sharing the retrier would race on.
+	bo.Backoff(0)
+	return &slackRetrier{bo: &bo, max: max}
+}
+
+var defaultRetrier = newSlackRetrier(10, backoff.Backoff{Jitter: 0.2})
+
+const (
+	slackTimeout = 10 * time.Second
+	// slackFatalTimeout bounds the synchronous delivery of the notifications
+	// of the entries about to terminate the process.
+	slackFatalTimeout = 3 * time.Second
+)
+
+// SlackMessageBuilder builds the Slack message of an entry, fields being the
+// fields of the entry as written by the core. Returning nil skips the
+// notification.
+type SlackMessageBuilder func(ent zapcore.Entry, fields []zapcore.Field, svc ServiceContext) *slack.WebhookMessage
+
+// notifyLocation returns the time zone of the notification timestamps.
+func (s *stackdriver) notifyLocation() *time.Location {
+	if s.notifyLoc == nil {
+		return time.Local
+	}
+	return s.notifyLoc
+}
+
+// slackNotifier posts the entries to a Slack incoming webhook, or to a
+// channel with the Web API.
+type slackNotifier struct {
+	url      string
+	client   *slack.Client
+	channel  string
+	build    SlackMessageBuilder
+	mentions []mentionRule
+	svcCtx   ServiceContext
+	loc      *time.Location
+}
+
+// slackNotifier returns the notifier of dest, either a webhook url or a
+// channel name or id.
+func (s *stackdriver) slackNotifier(dest string) *slackNotifier {
+	n := &slackNotifier{build: s.slackBuilder, mentions: s.mentions, svcCtx: s.svcCtx, loc: s.notifyLocation()}
+	if isSlackWebhook(dest) {
+		n.url = dest
+	} else {
+		n.client, n.channel = s.slackClient, dest
+	}
+	return n
+}
+
+func isSlackWebhook(dest string) bool {
+	return strings.HasPrefix(dest, "https://") || strings.HasPrefix(dest, "http://")
+}
+
+// permanentError is an error not worth retrying.
+type permanentError struct {
+	error
+}
+
+func (permanentError) Retryable() bool {
+	return false
+}
+
+// post posts msg to the webhook or the channel.
+func (n *slackNotifier) post(ctx context.Context, msg *slack.WebhookMessage) error {
+	if n.url != "" {
+		return slack.PostWebhookContext(ctx, n.url, msg)
+	}
+	if n.client == nil {
+		return permanentError{fmt.Errorf("no slack token to post to channel %q", n.channel)}
+	}
+	var opts []slack.MsgOption
+	if msg.Text != "" {
+		opts = append(opts, slack.MsgOptionText(msg.Text, false))
+	}
+	if msg.Blocks != nil {
+		opts = append(opts, slack.MsgOptionBlocks(msg.Blocks.BlockSet...))
+	}
+	if len(msg.Attachments) != 0 {
+		opts = append(opts, slack.MsgOptionAttachments(msg.Attachments...))
+	}
+	_, _, err := n.client.PostMessageContext(ctx, n.channel, opts...)
+	return err
+}
 
-func (s *stackdriver) sendSlackNotification(slackurl string, ent zapcore.Entry, fields []zapcore.Field) {
-	defer s.slackWG.Done()
-	if slackurl == "" {
-		return
+// Notify is Notifier implementation.
+func (n *slackNotifier) Notify(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+	var msg *slack.WebhookMessage
+	if n.build != nil {
+		msg = n.build(ent, fields, n.svcCtx)
+	} else {
+		msg = n.message(ent, fields)
+	}
+	if msg == nil {
+		return nil
+	}
+	var mentions []string
+	for _, r := range n.mentions {
+		if r.match(ent, fields) {
+			mentions = append(mentions, r.mention)
+		}
+	}
+	if len(mentions) != 0 {
+		if msg.Text != "" {
+			mentions = append(mentions, msg.Text)
+		}
+		msg.Text = strings.Join(mentions, " ")
 	}
+	return n.post(ctx, msg)
+}
+
+// mentionRule mentions someone in the notifications of the entries matching.
+type mentionRule struct {
+	match   Matcher
+	mention string
+}
+
+// message builds the default message of an entry, a header with the message,
+// caller, service, version, time and error followed by the fields.
+func (n *slackNotifier) message(ent zapcore.Entry, fields []zapcore.Field) *slack.WebhookMessage {
 	color, ok := levelColorMap[ent.Level]
 	if !ok {
-		return
+		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	enc := &slackEncoder{}
+	enc := &slackEncoder{loc: n.loc}
 	for _, field := range fields {
 		field.AddTo(enc)
 	}
@@ -72,20 +187,20 @@ func (s *stackdriver) sendSlackNotification(slackurl string, ent zapcore.Entry,
 		Type: slack.MBTSection,
 		Text: &slack.TextBlockObject{
 			Type: "mrkdwn",
-			Text: fmt.Sprintf("*%s*\n%s", ent.Message, ent.Caller.String()),
+			Text: truncateSlackText(fmt.Sprintf("*%s*\n%s", ent.Message, ent.Caller.String()), slackTextMaxLen),
 		},
 		Fields: []*slack.TextBlockObject{
 			{
 				Type: "mrkdwn",
-				Text: fmt.Sprintf("*%s*\n%s", "Service", s.svcCtx.Service),
+				Text: fmt.Sprintf("*%s*\n%s", "Service", n.svcCtx.Service),
 			},
 			{
 				Type: "mrkdwn",
-				Text: fmt.Sprintf("*%s*\n%s", "Version", s.svcCtx.Version),
+				Text: fmt.Sprintf("*%s*\n%s", "Version", n.svcCtx.Version),
 			},
 			{
 				Type: "mrkdwn",
-				Text: fmt.Sprintf("*%s*\n%s", "Time", ent.Time.Format(time.RFC3339)),
+				Text: fmt.Sprintf("*%s*\n%s", "Time", ent.Time.In(enc.loc).Format(time.RFC3339)),
 			},
 		},
 	}
@@ -96,42 +211,66 @@ func (s *stackdriver) sendSlackNotification(slackurl string, ent zapcore.Entry,
 		Color: color,
 		// Footer: fmt.Sprintf("reported at %s by %s"+ent.Time.Format(time.RFC3339), ent.LoggerName),
 	}
+	blocks := []slack.Block{head}
 	if len(enc.Fields) != 0 {
-		section := slack.SectionBlock{
-			Type:   slack.MBTSection,
-			Fields: enc.Fields,
-		}
-		attachment.Blocks = slack.Blocks{
-			BlockSet: []slack.Block{
-				head,
-				slack.NewDividerBlock(),
-				section,
-			},
+		blocks = append(blocks, slack.NewDividerBlock())
+		fields := enc.Fields
+		if len(fields) > slackMaxFields {
+			more := len(fields) - slackMaxFields + 1
+			fields = append(fields[:slackMaxFields-1:slackMaxFields-1], &slack.TextBlockObject{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("… %d more fields truncated", more),
+			})
 		}
-	} else {
-		attachment.Blocks = slack.Blocks{
-			BlockSet: []slack.Block{
-				head,
-			},
+		for len(fields) > 0 {
+			n := min(len(fields), slackSectionFields)
+			blocks = append(blocks, slack.SectionBlock{
+				Type:   slack.MBTSection,
+				Fields: fields[:n],
+			})
+			fields = fields[n:]
 		}
 	}
+	attachment.Blocks = slack.Blocks{BlockSet: blocks}
 
-	payload := &slack.WebhookMessage{
+	return &slack.WebhookMessage{
 		Attachments: []slack.Attachment{attachment},
 	}
+}
 
-	send := func(ctx context.Context) error {
-		return slack.PostWebhookContext(ctx, slackurl, payload)
-	}
+// The limits of the Slack blocks, beyond which the whole message is rejected.
+const (
+	slackTextMaxLen    = 3000
+	slackFieldMaxLen   = 2000
+	slackSectionFields = 10
+	// slackMaxFields caps the fields of a message, so that they fit in the 50
+	// blocks of a message along with the header and the divider.
+	slackMaxFields = 40 * slackSectionFields
+)
 
-	if err := backoff.Invoke(ctx, send, defaultRetrier.Retry); err != nil {
-		grpclog.Infof("zapx: failed to post slack notification after 10 retries: %v", err)
+// truncateSlackText truncates text to max bytes, closing an open code block
+// and marking it as truncated.
+func truncateSlackText(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	const marker = "\n… truncated"
+	n := max - len(marker) - len("```")
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
 	}
+	cut := text[:n]
+	if strings.Count(cut, "```")%2 == 1 {
+		cut += "```"
+	}
+	return cut + marker
 }
 
 type slackEncoder struct {
 	Fields   []*slack.TextBlockObject
 	ErrField *slack.TextBlockObject
+	// loc is the time zone the timestamps are rendered in.
+	loc *time.Location
 }
 
 func (enc *slackEncoder) sort() {
@@ -141,6 +280,7 @@ func (enc *slackEncoder) sort() {
 }
 
 func (enc *slackEncoder) addField(key string, field *slack.TextBlockObject) {
+	field.Text = truncateSlackText(field.Text, slackFieldMaxLen)
 	if key == "error" {
 		enc.ErrField = field
 	} else {
@@ -163,6 +303,20 @@ func (enc *slackEncoder) AddObject(key string, value zapcore.ObjectMarshaler) er
 	if key == "serviceContext" {
 		return nil
 	}
+	if m, ok := value.(multiError); ok {
+		// a field per error joined
+		enc.addField(key, &slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("*%s*\n%d errors", key, len(m.errs)),
+		})
+		for i, err := range m.errs {
+			enc.Fields = append(enc.Fields, &slack.TextBlockObject{
+				Type: "mrkdwn",
+				Text: truncateSlackText(fmt.Sprintf("*%s[%d]*\n%s", key, i, err), slackFieldMaxLen),
+			})
+		}
+		return nil
+	}
 	buf, err := yaml.Marshal(value)
 	if err != nil {
 		return err
@@ -266,7 +420,7 @@ func (enc *slackEncoder) AddString(key, value string) {
 func (enc *slackEncoder) AddTime(key string, value time.Time) {
 	enc.addField(key, &slack.TextBlockObject{
 		Type: "mrkdwn",
-		Text: fmt.Sprintf("*%s*\ntime=%s (%d)", key, value.Local().Format(time.RFC3339), value.Unix()),
+		Text: fmt.Sprintf("*%s*\ntime=%s (%d)", key, value.In(enc.loc).Format(time.RFC3339), value.Unix()),
 	})
 }
 func (enc *slackEncoder) AddUint(key string, value uint) {
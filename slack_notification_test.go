@@ -0,0 +1,30 @@
+package zapx
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateSlackText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want string
+	}{
+		{"short", "hello", 100, "hello"},
+		{"ascii", strings.Repeat("a", 50), 30, strings.Repeat("a", 13) + "\n… truncated"},
+		{"multibyte backs off to a rune start", strings.Repeat("語", 20), 30, strings.Repeat("語", 4) + "\n… truncated"},
+		{"open code block closed", "```" + strings.Repeat("a", 50), 40, "```" + strings.Repeat("a", 20) + "```\n… truncated"},
+	}
+	for _, tt := range tests {
+		got := truncateSlackText(tt.text, tt.max)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > tt.max || !utf8.ValidString(got) {
+			t.Errorf("%s: %q exceeds %d bytes or is invalid UTF-8", tt.name, got, tt.max)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package zapx
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// slogHandler is a slog.Handler writing to a zap logger.
+type slogHandler struct {
+	logger *zap.Logger
+	// groups are the groups opened with WithGroup and not holding any
+	// attribute yet.
+	groups []string
+}
+
+// NewSlogHandler returns a slog.Handler writing to logger, so that log/slog
+// gets the same output as zap: the levels are mapped to the closest zap
+// level, the trace of the context is attached, see Context, and the
+// attributes holding a zap field, e.g. slog.Any("", zapx.Slack()), are passed
+// as is, so that the special fields of zapx keep working.
+func NewSlogHandler(logger *zap.Logger) slog.Handler {
+	return &slogHandler{logger: logger}
+}
+
+// Enabled is slog.Handler implementation.
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Core().Enabled(slogLevel(level))
+}
+
+// Handle is slog.Handler implementation.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ce := h.logger.Check(slogLevel(r.Level), r.Message)
+	if ce == nil {
+		return nil
+	}
+	if !r.Time.IsZero() {
+		ce.Time = r.Time
+	}
+	// the logger does not capture the callers, see WithDisableCaller.
+	if r.PC != 0 && ce.Caller.Defined {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ce.Caller = zapcore.EntryCaller{
+			Defined:  true,
+			PC:       r.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
+		}
+	}
+	fields := make([]zapcore.Field, 0, r.NumAttrs()+len(h.groups)+1)
+	if ctx != nil {
+		if info := contextInfoFrom(ctx); !info.empty() {
+			fields = append(fields, zap.Reflect(logKeyContextInfo, info))
+		}
+	}
+	var attrs []zapcore.Field
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendSlogAttr(attrs, a)
+		return true
+	})
+	if len(attrs) != 0 {
+		for _, g := range h.groups {
+			fields = append(fields, zap.Namespace(g))
+		}
+		fields = append(fields, attrs...)
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+// WithAttrs is slog.Handler implementation.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []zapcore.Field
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, a)
+	}
+	if len(fields) == 0 {
+		return h
+	}
+	fs := make([]zapcore.Field, 0, len(h.groups)+len(fields))
+	for _, g := range h.groups {
+		fs = append(fs, zap.Namespace(g))
+	}
+	return &slogHandler{logger: h.logger.With(append(fs, fields...)...)}
+}
+
+// WithGroup is slog.Handler implementation.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &slogHandler{logger: h.logger, groups: append(groups, name)}
+}
+
+// slogLevel returns the zap level of a slog level.
+func slogLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// appendSlogAttr appends the zap fields of a to fields.
+func appendSlogAttr(fields []zapcore.Field, a slog.Attr) []zapcore.Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, a.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, a.Value.Time()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		if a.Key == "" {
+			for _, ga := range attrs {
+				fields = appendSlogAttr(fields, ga)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(a.Key, slogGroup(attrs)))
+	}
+	switch v := a.Value.Any().(type) {
+	case zapcore.Field:
+		return append(fields, v)
+	case error:
+		return append(fields, zap.NamedError(a.Key, v))
+	default:
+		return append(fields, zap.Any(a.Key, v))
+	}
+}
+
+// slogGroup is the attributes of a slog group.
+type slogGroup []slog.Attr
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (g slogGroup) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	var fields []zapcore.Field
+	for _, a := range g {
+		fields = appendSlogAttr(fields, a)
+	}
+	for _, f := range fields {
+		f.AddTo(e)
+	}
+	return nil
+}
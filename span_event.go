@@ -0,0 +1,79 @@
+package zapx
+
+import (
+	"fmt"
+	"strings"
+
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// spanRecorder records entries as events of the span found in the context
+// passed to Context.
+type spanRecorder struct {
+	otel oteltrace.Span
+	oc   *octrace.Span
+}
+
+func (r *spanRecorder) record(ent zapcore.Entry, fields []zapcore.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		// the span already knows about the trace
+		if strings.HasPrefix(f.Key, "logging.googleapis.com/") {
+			continue
+		}
+		f.AddTo(enc)
+	}
+	if r.otel != nil && r.otel.IsRecording() {
+		attrs := make([]attribute.KeyValue, 0, len(enc.Fields)+1)
+		attrs = append(attrs, attribute.String("severity", ent.Level.CapitalString()))
+		for key, val := range enc.Fields {
+			attrs = append(attrs, otelAttribute(key, val))
+		}
+		r.otel.AddEvent(ent.Message, oteltrace.WithAttributes(attrs...), oteltrace.WithTimestamp(ent.Time))
+	}
+	if r.oc != nil && r.oc.IsRecordingEvents() {
+		attrs := make([]octrace.Attribute, 0, len(enc.Fields)+1)
+		attrs = append(attrs, octrace.StringAttribute("severity", ent.Level.CapitalString()))
+		for key, val := range enc.Fields {
+			attrs = append(attrs, ocAttribute(key, val))
+		}
+		r.oc.Annotate(attrs, ent.Message)
+	}
+}
+
+func otelAttribute(key string, val interface{}) attribute.KeyValue {
+	switch v := val.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
+
+func ocAttribute(key string, val interface{}) octrace.Attribute {
+	switch v := val.(type) {
+	case string:
+		return octrace.StringAttribute(key, v)
+	case bool:
+		return octrace.BoolAttribute(key, v)
+	case int64:
+		return octrace.Int64Attribute(key, v)
+	case int:
+		return octrace.Int64Attribute(key, int64(v))
+	case float64:
+		return octrace.Float64Attribute(key, v)
+	default:
+		return octrace.StringAttribute(key, fmt.Sprint(v))
+	}
+}
@@ -1,18 +1,30 @@
 package zapx
 
 import (
+	"context"
+	"math"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/slack-go/slack"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
 	logKeySlackNotification = "zapx.slack"
 	logKeyContextInfo       = "zapx.context"
 	logKeyLabelPrefix       = "zapx.label#"
+	logKeyLogID             = "zapx.log_id"
+	logKeyAudit             = "zapx.audit"
+	logKeyMinimal           = "zapx.minimal"
+	logKeyRecovered         = "zapx.recovered"
+	logKeyMetricPrefix      = "zapx.metric#"
 )
 
 type slackBehavior int
@@ -23,37 +35,302 @@ const (
 	disableSlack
 )
 
-// Zap returns a zap logger configured to output logs to stdout and stderr.
+// Zap returns a zap logger configured to output logs to stdout and stderr,
+// see WithOutput.
 func Zap(level zapcore.Level, opts ...Option) *zap.Logger {
 	opt := &option{
 		slackURL:  "",
 		projectID: "",
 		service:   "unknown",
 		version:   "unknown",
+
+		notifyWorkers: 4,
+		notifyQueue:   256,
+		clock:         zapcore.DefaultClock,
 	}
 	for _, o := range opts {
 		o(opt)
 	}
+	var globalLabels labels
+	if opt.autoDetect {
+		env := detectEnvironment(context.Background())
+		if opt.projectID == "" {
+			opt.projectID = env.projectID
+		}
+		if opt.service == "unknown" && env.service != "" {
+			opt.service = env.service
+		}
+		if opt.version == "unknown" && env.version != "" {
+			opt.version = env.version
+		}
+		globalLabels = env.labels()
+	}
+	globalLabels = globalLabels.merge(staticLabels(opt.labels))
 	enabler := zap.NewAtomicLevel()
+	if opt.level != nil {
+		enabler = *opt.level
+	}
 	enabler.SetLevel(level)
-	stdout := zapcore.Lock(os.Stdout)
-	enc := zapcore.NewJSONEncoder(StackdriverEncoderConfig)
-	core := zapcore.NewCore(enc, stdout, enabler)
-	logger := zap.New(core, zap.AddCaller())
+	res := &resources{}
+	var out zapcore.WriteSyncer = zapcore.Lock(os.Stdout)
+	if opt.output != nil {
+		out = zapcore.Lock(opt.output)
+	}
+	if opt.bufferSize > 0 || opt.flushInterval > 0 {
+		buffered := &zapcore.BufferedWriteSyncer{WS: out, Size: opt.bufferSize, FlushInterval: opt.flushInterval}
+		res.add(closerFunc(buffered.Stop))
+		out = buffered
+	}
+	var sinkEnv SinkEnv
+	if len(opt.sinks) != 0 {
+		sinkEnv = opt.sinkEnv()
+	}
+	for _, so := range opt.sinks {
+		if !so.output {
+			continue
+		}
+		if sink, err := so.build(sinkEnv); err != nil {
+			opt.onError.errorf("zapx: failed to create the output sink, writing to stdout: %w", err)
+		} else {
+			res.add(sink)
+			out = sink
+		}
+	}
+	encCfg := StackdriverEncoderConfig
+	if opt.encoderConfig != nil {
+		encCfg = *opt.encoderConfig
+	}
+	if opt.timeEncoder != nil {
+		encCfg.EncodeTime = opt.timeEncoder
+	}
+	if opt.fullCaller {
+		encCfg.EncodeCaller = zapcore.FullCallerEncoder
+	}
+	var enc zapcore.Encoder
+	if opt.development {
+		encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encCfg.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05.000")
+		encCfg.EncodeDuration = zapcore.StringDurationEncoder
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	} else {
+		enc = zapcore.NewJSONEncoder(encCfg)
+	}
+	core := zapcore.NewCore(enc, out, enabler)
+	if opt.file != nil {
+		res.add(opt.file)
+		core = zapcore.NewTee(core, zapcore.NewCore(enc.Clone(), zapcore.AddSync(opt.file), enabler))
+	}
+	if opt.syslogNetwork != nil {
+		if sink, err := newSyslogSink(*opt.syslogNetwork, opt.syslogAddr, opt.syslogTag); err != nil {
+			opt.onError.errorf("zapx: failed to connect to syslog: %w", err)
+		} else {
+			res.add(sink)
+			core = zapcore.NewTee(core, zapcore.NewCore(enc.Clone(), sink, enabler))
+		}
+	}
+	if opt.journald != nil {
+		svcCtx := ServiceContext{Service: opt.service, Version: opt.version}
+		if sink, err := newJournaldSink(*opt.journald, svcCtx); err != nil {
+			opt.onError.errorf("zapx: failed to connect to journald: %w", err)
+		} else {
+			res.add(sink)
+			core = zapcore.NewTee(core, zapcore.NewCore(enc.Clone(), sink, enabler))
+		}
+	}
+	if opt.fluentAddr != "" {
+		if sink, err := newFluentSink(opt.fluentNetwork, opt.fluentAddr, opt.fluentTag, opt.onError); err != nil {
+			opt.onError.errorf("zapx: failed to connect to fluentd: %w", err)
+		} else {
+			res.add(sink)
+			core = zapcore.NewTee(core, zapcore.NewCore(enc.Clone(), sink, enabler))
+		}
+	}
+	for _, so := range opt.sinks {
+		if so.output {
+			continue
+		}
+		if sink, err := so.build(sinkEnv); err != nil {
+			opt.onError.errorf("zapx: failed to create a sink: %w", err)
+		} else {
+			res.add(sink)
+			core = zapcore.NewTee(core, zapcore.NewCore(enc.Clone(), sink, enabler))
+		}
+	}
+	if len(opt.cores) != 0 {
+		core = append(checkedTee{core}, opt.cores...)
+	}
+	zopts := []zap.Option{zap.WithClock(opt.clock)}
+	if !opt.disableCaller {
+		zopts = append(zopts, zap.AddCaller())
+	}
+	if opt.dpanicPanics {
+		zopts = append(zopts, zap.Development())
+	}
+	if opt.stacktraceLevel != nil {
+		zopts = append(zopts, zap.AddStacktrace(*opt.stacktraceLevel))
+	}
+	if opt.callerSkip != 0 {
+		zopts = append(zopts, zap.AddCallerSkip(opt.callerSkip))
+	}
+	logger := zap.New(core, zopts...)
 	logger = logger.Named(opt.service)
 	return logger.WithOptions(zap.WrapCore(
 		func(core zapcore.Core) zapcore.Core {
-			return &stackdriver{
-				projectID:   opt.projectID,
-				parent:      core,
-				svcCtx:      serviceContext{Service: opt.service, Version: opt.version},
-				slackURL:    opt.slackURL,
-				errorPraser: opt.errorParser,
+			s := &stackdriver{
+				projectID:      opt.projectID,
+				minimal:        opt.development,
+				parent:         core,
+				svcCtx:         ServiceContext{Service: opt.service, Version: opt.version},
+				slackURL:       opt.slackURL,
+				slackLevels:    opt.slackLevels,
+				slackBuilder:   opt.slackBuilder,
+				mentions:       opt.mentions,
+				notifyRoutes:   opt.notifyRoutes,
+				slackLevel:     opt.slackLevel,
+				notifiers:      opt.notifiers,
+				errorPraser:    chainErrorParsers(opt.errorParsers),
+				errorChain:     opt.errorChain,
+				redactor:       newRedactor(opt.redactKeys, opt.redactPatterns),
+				stats:          opt.stats,
+				onError:        opt.onError,
+				res:            res,
+				reqRedaction:   newRequestRedaction(opt.redactQuery, opt.requestHeaders),
+				throttler:      newThrottler(),
+				notifyLoc:      opt.notifyLoc,
+				retrier:        defaultRetrier,
+				deadLetters:    opt.deadLetters,
+				notifyTimeout:  slackTimeout,
+				metricRecorder: opt.metricRecorder,
+				protoTypes:     opt.protoTypes,
+				protoMax:       opt.protoMax,
+				rawSpanID:      opt.rawSpanID,
+				alwaysTrace:    opt.alwaysTrace,
+				spanEvents:     opt.spanEvents,
+				traceLinks:     opt.traceLinks,
+				traceProjID:    opt.traceProjectID,
+				onFatal:        opt.onFatal,
+				onPanic:        opt.onPanic,
+				dpanicPanics:   opt.dpanicPanics,
+				labelProvider:  opt.labelProvider,
+				requestIDKeys:  opt.requestIDKeys,
+				sampledDebug:   opt.sampledDebug,
+				retainFields:   len(opt.cores) != 0,
+				processors:     opt.processors,
+				callerPath:     trimmedCallerPath,
+				callerFuncOnly: opt.callerFuncOnly,
+				clock:          opt.clock,
+				mdMaxValue:     opt.mdMaxValue,
+				mdMaxTotal:     opt.mdMaxTotal,
+				mdLabels:       opt.mdLabels,
+				mdDropRest:     opt.mdDropRest,
+				labels:         globalLabels,
+			}
+			if s.stats == nil {
+				s.stats = nopStats{}
+			}
+			if opt.maxEntrySize > 0 {
+				s.truncator = &entryTruncator{enc: enc.Clone(), maxBytes: opt.maxEntrySize}
+			}
+			if opt.auditSink != nil {
+				s.auditCore = zapcore.NewCore(enc.Clone(), zapcore.Lock(opt.auditSink), enabler)
+			}
+			if opt.routeKey != "" {
+				s.router = newRouter(opt.routeKey, opt.routes, enc, enabler)
+			}
+			if opt.sampleRates != nil {
+				s.sampler = newLevelSampler(opt.sampleRates, opt.sampleReport)
+			}
+			if s.projectID == "" {
+				s.projectID = detectProjectID()
+			}
+			if opt.detectResource {
+				s.resource = detectResource(context.Background(), s.projectID)
+				if s.projectID == "" {
+					s.projectID = s.resource.Labels["project_id"]
+				}
+			}
+			if opt.fullCaller {
+				s.callerPath = fullCallerPath
+			} else if opt.callerPrefix != "" {
+				s.callerPath = prefixCallerPath(opt.callerPrefix)
+			} else if opt.moduleCaller {
+				s.callerPath = moduleCallerPath()
+			}
+			if opt.retryBackoff != nil {
+				s.retrier = newSlackRetrier(opt.retryMax, *opt.retryBackoff)
+			}
+			if opt.notifyTimeout > 0 {
+				s.notifyTimeout = opt.notifyTimeout
+			}
+			s.notifyPool = newNotifyPool(s, opt.notifyWorkers, opt.notifyQueue, opt.notifyOverflow)
+			if opt.syncTimeout != 0 {
+				s.notifyPool.syncTimeout = opt.syncTimeout
+			}
+			if opt.notifyRate > 0 {
+				s.notifyLimit = newNotifyLimiter(opt.notifyRate, opt.notifyBurst, s.clock, s.notifySuppressed)
+			}
+			if opt.notifyDedup > 0 {
+				s.notifyDedup = newNotifyDeduper(opt.notifyDedup, s.notifyPool.enqueue)
+			}
+			if opt.slackToken != "" {
+				s.slackClient = slack.New(opt.slackToken)
+				if s.slackURL == "" {
+					s.slackURL = opt.slackChannel
+				}
+			}
+			if opt.slackDigest > 0 {
+				s.slackDigest = newSlackDigest(s, opt.slackDigest)
+			}
+			if opt.breakerFailures > 0 {
+				s.breakers = newBreakers(opt.breakerFailures, opt.breakerCooldown, opt.onError)
+			}
+			if opt.dedupWindow > 0 {
+				s.deduper = newDeduper(opt.dedupWindow, opt.clock)
+			}
+			if opt.errorReporter != nil {
+				if r, err := opt.errorReporter(opt.sinkEnv()); err != nil {
+					opt.onError.errorf("zapx: failed to create the error reporter: %w", err)
+				} else {
+					s.errorReporter = newErrorReporter(r, s.redactor, s.reqRedaction, opt.onError)
+				}
+			}
+			s.cacheFields()
+			var wrapped zapcore.Core = s
+			if opt.sampleInitial > 0 || opt.sampleAfter > 0 {
+				sampler := zapcore.NewSamplerWithOptions(s, time.Second, opt.sampleInitial, opt.sampleAfter, zapcore.SamplerHook(func(ent zapcore.Entry, dec zapcore.SamplingDecision) {
+					if dec&zapcore.LogDropped != 0 {
+						s.stats.EntryDropped(ent.Level, DropSampling)
+					}
+				}))
+				wrapped = sampledCore{Core: sampler, s: s}
+			}
+			if opt.repeatWindow > 0 {
+				wrapped = NewRepeatCore(wrapped, opt.repeatWindow, opt.repeatThreshold)
 			}
+			return wrapped
 		},
 	))
 }
 
+// ZapWithLevel is Zap, also returning the level of the logger to change it at
+// runtime. The level is an http.Handler reporting the level on GET and
+// changing it on PUT, e.g. to turn on the debug entries of a production
+// service without redeploying it:
+//
+//	logger, lvl := zapx.ZapWithLevel(zapcore.InfoLevel)
+//	http.Handle("/log/level", lvl)
+//
+//	curl -X PUT -d '{"level":"debug"}' localhost:8080/log/level
+func ZapWithLevel(level zapcore.Level, opts ...Option) (*zap.Logger, zap.AtomicLevel) {
+	lvl := zap.NewAtomicLevel()
+	return Zap(level, append(opts, WithAtomicLevel(lvl))...), lvl
+}
+
+// ZapDev is Zap with WithDevelopment.
+func ZapDev(level zapcore.Level, opts ...Option) *zap.Logger {
+	return Zap(level, append(opts, WithDevelopment())...)
+}
+
 // StackdriverEncoderConfig is a encoder config for stackdriver.
 var StackdriverEncoderConfig = zapcore.EncoderConfig{
 	MessageKey:    "message",
@@ -95,16 +372,40 @@ type contextInfo struct {
 	SpanID     string
 	GrpcMethod string
 	RequestID  string
+	// RawSpanID is the span id as received, when it had to be normalized.
+	RawSpanID string
+
+	recorder *spanRecorder
+	// md is the incoming metadata, where the request id is looked up by the
+	// core, with its keys, if not in the context.
+	md metadata.MD
+	// requestIDLabel reports whether the request id is carried by the
+	// "request_id" label, see ForRequest, rather than a field.
+	requestIDLabel bool
+}
+
+// empty reports whether info carries nothing to log.
+func (info contextInfo) empty() bool {
+	return info.TraceID == "" && info.GrpcMethod == "" && info.RequestID == "" && info.recorder == nil && info.md == nil
+}
+
+// requestID returns the request id of info, looked up with keys in the
+// incoming metadata if not in the context.
+func (info contextInfo) requestID(keys []string) string {
+	if info.RequestID != "" || info.md == nil {
+		return info.RequestID
+	}
+	return requestIDFromMetadata(info.md, keys)
 }
 
-// serviceContext is the service context for which this error was reported.
-type serviceContext struct {
+// ServiceContext is the service context for which this error was reported.
+type ServiceContext struct {
 	Service string
 	Version string
 }
 
 // MarshalLogObject is ObjectMarshaler implementation.
-func (s serviceContext) MarshalLogObject(e zapcore.ObjectEncoder) error {
+func (s ServiceContext) MarshalLogObject(e zapcore.ObjectEncoder) error {
 	e.AddString("service", s.Service)
 	e.AddString("version", s.Version)
 	return nil
@@ -115,103 +416,718 @@ func (s serviceContext) MarshalLogObject(e zapcore.ObjectEncoder) error {
 // https://cloud.google.com/error-reporting/docs/formatting-error-messages for
 // the format details.
 type stackdriver struct {
-	projectID   string
-	parent      zapcore.Core
-	svcCtx      serviceContext
+	projectID string
+	parent    zapcore.Core
+	svcCtx    ServiceContext
+	// slackURL is the default slack destination, a webhook url or a channel.
 	slackURL    string
+	slackClient *slack.Client
+	// slackLevels are the slack destinations by level, see WithSlackURLs.
+	slackLevels  map[zapcore.Level]string
+	slackBuilder SlackMessageBuilder
+	mentions     []mentionRule
+	notifyRoutes []notifyRoute
+	// slackLevel is the level from which the entries are notified.
+	slackLevel  *zapcore.Level
 	errorPraser func(error) (zapcore.ObjectMarshaler, bool)
-	slackWG     sync.WaitGroup
+	errorChain  bool
+	notifiers   []Notifier
+	notifyPool  *notifyPool
+	retrier     *slackRetrier
+	deadLetters DeadLetterFunc
+	// notifyTimeout bounds the delivery of a notification, retries included.
+	notifyTimeout time.Duration
+	notifyLimit   *notifyLimiter
+	notifyDedup   *notifyDeduper
+	slackDigest   *slackDigest
+	throttler     *throttler
+	breakers      *breakers
+	notifyLoc     *time.Location
+	// metricRecorder is called with the metrics of every entry written.
+	metricRecorder func(name string, value float64)
+	deduper        *deduper
+	errorReporter  *errorReporter
+	redactor       *redactor
+	reqRedaction   *requestRedaction
+	truncator      *entryTruncator
+	stats          Stats
+	onError        errorHandler
+	res            *resources
+	protoTypes     ProtoResolver
+	protoMax       int
+	rawSpanID      bool
+	alwaysTrace    bool
+	spanEvents     *zapcore.Level
+	traceLinks     bool
+	traceProjID    string
+	onFatal        func(zapcore.Entry)
+	onPanic        func(zapcore.Entry)
+	dpanicPanics   bool
+	labelProvider  func(zapcore.Entry) map[string]string
+	requestIDKeys  []string
+	// auditCore writes the audit entries, see WithAuditSink.
+	auditCore zapcore.Core
+	// sampledDebug writes the entries below the level of the sampled
+	// traces, see WithSampledTraceDebug.
+	sampledDebug bool
+	// processors rewrite the fields of the entries, see WithFieldProcessor.
+	processors []func(zapcore.Entry, []zapcore.Field) []zapcore.Field
+	// retainFields is whether the parent may retain the fields written,
+	// e.g. an observer, see WithCores, so that they are not pooled.
+	retainFields bool
+	callerPath   callerPath
+	// callerFuncOnly omits the file and line of the caller.
+	callerFuncOnly bool
+	// clock is the clock of the logger, for the notifications.
+	clock      zapcore.Clock
+	resource   *monitoredResource
+	router     *router
+	sampler    *levelSampler
+	mdMaxValue int
+	mdMaxTotal int
+	mdLabels   metadataLabels
+	mdDropRest bool
 
-	enableSlack bool
-	user        string
-	fields      []zapcore.Field
+	// sendSlack is whether the entries are notified, when not specified by
+	// the entry.
+	sendSlack slackBehavior
+	// minimal skips the enrichment of the entries below warn level.
+	minimal   bool
+	user      string
+	throttle  *throttleSpec
+	canonical *CanonicalLine
+	labels    labels
+	metrics   labels
+	fields    []zapcore.Field
+	// context is the trace attached with With, see Context; the one of an
+	// entry replaces it.
+	context *contextInfo
+	// nested are the fields attached under a zap.Namespace, they are always
+	// written after the top level ones.
+	nested []zapcore.Field
+	// ctxFields and labelsField are the fields of context and labels,
+	// resolved once rather than per entry, see cacheFields.
+	ctxFields   []zapcore.Field
+	labelsField zapcore.Field
+}
+
+// cacheFields resolves the fields of the trace and the labels attached to the
+// logger, written as is by the entries that carry none of their own.
+func (s *stackdriver) cacheFields() {
+	if s.context != nil {
+		s.ctxFields = s.contextFields(*s.context)
+	}
+	if len(s.labels) != 0 {
+		s.labelsField = zap.Object("logging.googleapis.com/labels", s.labels)
+	}
 }
 
 func (s *stackdriver) Enabled(l zapcore.Level) bool {
-	return s.parent.Enabled(l)
+	if s.parent.Enabled(l) {
+		return true
+	}
+	// the trace may be set on the entry, see Write.
+	return s.sampledDebug && l >= zapcore.DebugLevel && (s.context == nil || s.context.IsSampled)
 }
 
 func (s *stackdriver) With(fields []zapcore.Field) zapcore.Core {
-	fs, user, sendSlack, slackURL := s.parseFields(fields)
-	newFileds := make([]zapcore.Field, len(fs)+len(s.fields))
+	p := s.parseFields(nil, fields)
+	newFileds := make([]zapcore.Field, len(p.fields)+len(s.fields))
 
+	user := p.user
 	if user == "" {
 		user = s.user
 	}
 	copy(newFileds, s.fields)
-	copy(newFileds[len(s.fields):], fs)
+	copy(newFileds[len(s.fields):], p.fields)
+	newNested := make([]zapcore.Field, 0, len(s.nested)+len(p.nested))
+	newNested = append(newNested, s.nested...)
+	newNested = append(newNested, p.nested...)
 
 	news := &stackdriver{
-		parent:      s.parent,
-		projectID:   s.projectID,
-		svcCtx:      s.svcCtx,
-		slackURL:    s.slackURL,
-		errorPraser: s.errorPraser,
+		parent:         s.parent,
+		projectID:      s.projectID,
+		svcCtx:         s.svcCtx,
+		slackURL:       s.slackURL,
+		slackClient:    s.slackClient,
+		slackLevels:    s.slackLevels,
+		slackBuilder:   s.slackBuilder,
+		mentions:       s.mentions,
+		notifyRoutes:   s.notifyRoutes,
+		slackLevel:     s.slackLevel,
+		notifiers:      s.notifiers,
+		notifyPool:     s.notifyPool,
+		retrier:        s.retrier,
+		deadLetters:    s.deadLetters,
+		notifyTimeout:  s.notifyTimeout,
+		notifyLimit:    s.notifyLimit,
+		notifyDedup:    s.notifyDedup,
+		slackDigest:    s.slackDigest,
+		errorPraser:    s.errorPraser,
+		errorChain:     s.errorChain,
+		throttler:      s.throttler,
+		breakers:       s.breakers,
+		notifyLoc:      s.notifyLoc,
+		metricRecorder: s.metricRecorder,
+		deduper:        s.deduper,
+		errorReporter:  s.errorReporter,
+		redactor:       s.redactor,
+		reqRedaction:   s.reqRedaction,
+		truncator:      s.truncator,
+		stats:          s.stats,
+		onError:        s.onError,
+		res:            s.res,
+		protoTypes:     s.protoTypes,
+		protoMax:       s.protoMax,
+		rawSpanID:      s.rawSpanID,
+		alwaysTrace:    s.alwaysTrace,
+		spanEvents:     s.spanEvents,
+		traceLinks:     s.traceLinks,
+		traceProjID:    s.traceProjID,
+		onFatal:        s.onFatal,
+		onPanic:        s.onPanic,
+		dpanicPanics:   s.dpanicPanics,
+		labelProvider:  s.labelProvider,
+		requestIDKeys:  s.requestIDKeys,
+		auditCore:      s.auditCore,
+		sampledDebug:   s.sampledDebug,
+		retainFields:   s.retainFields,
+		processors:     s.processors,
+		callerPath:     s.callerPath,
+		callerFuncOnly: s.callerFuncOnly,
+		clock:          s.clock,
+		resource:       s.resource,
+		router:         s.router,
+		sampler:        s.sampler,
+		mdMaxValue:     s.mdMaxValue,
+		mdMaxTotal:     s.mdMaxTotal,
+		mdLabels:       s.mdLabels,
+		mdDropRest:     s.mdDropRest,
 
-		user:   user,
-		fields: newFileds,
+		sendSlack: s.sendSlack,
+		minimal:   s.minimal || p.minimal,
+		user:      user,
+		throttle:  s.throttle,
+		canonical: s.canonical,
+		context:   s.context,
+		labels:    s.labels.merge(p.labels),
+		metrics:   s.metrics.merge(p.metrics),
+		fields:    newFileds,
+		nested:    newNested,
 	}
 
-	if slackURL != "" {
-		news.slackURL = slackURL
+	if spec, ok := findThrottle(fields); ok {
+		news.throttle = &spec
+	}
+	if line := findCanonicalLine(fields); line != nil {
+		news.canonical = line
 	}
-	if sendSlack == disableSlack {
-		news.enableSlack = false
-	} else if sendSlack == enableSlack {
-		news.enableSlack = true
+	if p.context != nil {
+		news.context = p.context
 	}
 
+	if p.slackURL != "" {
+		news.slackURL = p.slackURL
+		news.slackLevels = nil
+	}
+	if p.sendSlack != defaultSlack {
+		news.sendSlack = p.sendSlack
+	}
+	news.cacheFields()
+
 	return news
 }
 
 func (s *stackdriver) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if s.Enabled(ent.Level) {
-		return ce.AddCore(ent, s)
+	if !s.Enabled(ent.Level) {
+		return ce
+	}
+	if s.sampler != nil && !s.sampler.sample(ent.Level) {
+		s.stats.EntryDropped(ent.Level, DropSampling)
+		return ce
 	}
-	return ce
+	return ce.AddCore(ent, s)
 }
 
 func (s *stackdriver) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !s.parent.Enabled(ent.Level) && !s.traceSampled(fields) {
+		// below the level, see WithSampledTraceDebug.
+		return nil
+	}
+	if s.sampler != nil {
+		if rent, rfs, ok := s.sampler.report(ent.Time); ok {
+			s.write(rent, rfs)
+		}
+	}
+	if s.deduper == nil {
+		return s.write(ent, fields)
+	}
+	flush, ok := s.deduper.observe(s, ent, fields)
+	var err error
+	if flush != nil {
+		err = flush.write()
+	}
+	if ok {
+		err = multierr.Append(err, s.write(ent, fields))
+	} else {
+		s.stats.EntryDropped(ent.Level, DropDedup)
+	}
+	return err
+}
+
+// maxPooledFields is the capacity above which the field slices of the
+// entries are not pooled, not to keep the ones of rare large entries.
+const maxPooledFields = 128
+
+// writeState is the scratch state of an entry being written, pooled to spare
+// the allocations of every write.
+type writeState struct {
+	fields, parsed []zapcore.Field
+	sloc           sourceLocation
+	ctx            errorReportingContext
+}
+
+var writeStatePool = sync.Pool{New: func() interface{} { return new(writeState) }}
+
+func getWriteState() *writeState {
+	return writeStatePool.Get().(*writeState)
+}
+
+// put returns st to the pool, once the entry is written and its fields no
+// longer referenced.
+func (st *writeState) put() {
+	if cap(st.fields) > maxPooledFields || cap(st.parsed) > maxPooledFields {
+		return
+	}
+	for i := range st.fields {
+		st.fields[i] = zapcore.Field{}
+	}
+	for i := range st.parsed {
+		st.parsed[i] = zapcore.Field{}
+	}
+	*st = writeState{fields: st.fields[:0], parsed: st.parsed[:0]}
+	writeStatePool.Put(st)
+}
+
+func (s *stackdriver) write(ent zapcore.Entry, fields []zapcore.Field) error {
+	for _, process := range s.processors {
+		fields = process(ent, fields)
+	}
 	if ent.LoggerName != "" && ent.LoggerName != "unknown" {
 		ent.Message = ent.LoggerName + ": " + ent.Message
 	}
-	rloc := reportLocationFromEntry(ent)
-	sloc := sourceLocationFromEntry(ent)
-	fs := fields
-
-	fs, user, sendSlack, slackURL := s.parseFields(fs, ent.Message)
-	fs = append(fs, s.fields...)
+	spec, throttled := findThrottle(fields)
+	if !throttled && s.throttle != nil {
+		spec, throttled = *s.throttle, true
+	}
+	var suppressed int64
+	if throttled {
+		var ok bool
+		if ok, suppressed = s.throttler.allow(spec, ent.Time); !ok {
+			s.stats.EntryDropped(ent.Level, DropThrottle)
+			return nil
+		}
+	}
+	line := findCanonicalLine(fields)
+	if line == nil {
+		line = s.canonical
+	}
+	if line != nil && !line.observe(ent) {
+		return nil
+	}
+	if ent.Level >= zapcore.ErrorLevel && !hasField(fields, "stack_trace") {
+		// report where the error was created rather than where it is logged.
+		if stack := errorStack(fields); stack != "" {
+			ent.Stack = stack
+		}
+	}
+	if ent.Stack != "" && hasField(fields, "stack_trace") {
+		// e.g. the stack of a recovered panic, see Recover.
+		ent.Stack = ""
+	}
+	if ent.Stack != "" {
+		// Error Reporting only understands stack traces in the form of a
+		// Go panic under stack_trace.
+		fields = append(fields[:len(fields):len(fields)], zap.String("stack_trace", panicStack(ent.Stack)))
+		ent.Stack = ""
+	}
+	st := getWriteState()
+	p := s.parseFields(st.parsed, fields, ent.Message)
+	st.parsed = p.fields
+	fs := append(st.fields[:0], s.fields...)
+	fs = append(fs, p.fields...)
+	info := p.context
+	if info != nil {
+		fs = append(fs, s.contextFields(*info)...)
+	} else {
+		info = s.context
+		fs = append(fs, s.ctxFields...)
+	}
+	if len(p.labels) == 0 && s.labelProvider == nil {
+		if len(s.labels) != 0 {
+			fs = append(fs, s.labelsField)
+		}
+	} else {
+		lbs := s.labels.merge(p.labels)
+		if s.labelProvider != nil {
+			lbs = staticLabels(s.labelProvider(ent)).merge(lbs)
+		}
+		if len(lbs) != 0 {
+			fs = append(fs, zap.Object("logging.googleapis.com/labels", lbs))
+		}
+	}
+	if ms := s.metrics.merge(p.metrics); len(ms) != 0 {
+		fs = append(fs, zap.Object("metrics", ms))
+		if s.metricRecorder != nil {
+			for _, m := range ms {
+				s.metricRecorder(m.Key, math.Float64frombits(uint64(m.Integer)))
+			}
+		}
+	}
+	if suppressed > 0 {
+		fs = append(fs, zap.Int64("suppressed", suppressed))
+	}
+	user := p.user
 	if user == "" {
 		user = s.user
 	}
-	fs = append(fs, zap.Object("logging.googleapis.com/sourceLocation", sloc), zap.Object("serviceContext", s.svcCtx), zap.Object("context", errorReportingContext{reportLocation: rloc, user: user}))
-	if sendSlack == enableSlack || (sendSlack == defaultSlack && s.enableSlack) {
-		s.slackWG.Add(1)
-		go s.sendSlackNotification(slackURL, ent, fs)
+	if p.audit != nil {
+		audit := *p.audit
+		if audit.principal == "" {
+			audit.principal = user
+		}
+		fs = append(fs, zap.Object("audit", audit))
+	}
+	if !(s.minimal || p.minimal) || ent.Level >= zapcore.WarnLevel {
+		if s.resource != nil {
+			fs = append(fs, zap.Object("resource", s.resource))
+		}
+		rloc := reportLocationFromEntry(ent, s.callerPath)
+		if s.callerFuncOnly {
+			rloc.filePath = ""
+		}
+		// the locations are pointed to in st, not to be boxed per entry.
+		if ent.Caller.Defined {
+			st.sloc = sourceLocation{file: rloc.filePath, line: rloc.lineNumber, function: rloc.functionName}
+			fs = append(fs, zap.Object("logging.googleapis.com/sourceLocation", &st.sloc))
+		}
+		fs = append(fs, zap.Object("serviceContext", &s.svcCtx))
+		if ent.Caller.Defined || user != "" {
+			st.ctx = errorReportingContext{reportLocation: rloc, user: user}
+			fs = append(fs, zap.Object("context", &st.ctx))
+		}
+	}
+	fs = append(fs, s.nested...)
+	fs = append(fs, p.nested...)
+	st.fields = fs
+	resolveTimers(fs)
+	spanFields := p.fields
+	if s.redactor != nil {
+		ent.Message = s.redactor.string(ent.Message)
+		fs = s.redactor.fields(fs)
+		spanFields = s.redactor.fields(spanFields)
+	}
+	if s.truncator != nil {
+		var truncated bool
+		if ent, fs, truncated = s.truncator.truncate(ent, fs); truncated {
+			fs = markTruncated(fs)
+		}
+	}
+	if s.spanEvents != nil && ent.Level >= *s.spanEvents {
+		if info != nil && info.recorder != nil {
+			info.recorder.record(ent, spanFields)
+		}
+	}
+	var retained bool
+	// the recovered panics don't terminate the process, see Recover.
+	terminates := s.terminates(ent.Level) && !p.recovered
+	if terminates {
+		// the process is about to terminate, deliver the notification and
+		// the pending ones before it does.
+		var targets []Notifier
+		if s.shouldNotify(ent, p.sendSlack) {
+			targets = s.entryTargets(ent, fs, p.slackURL)
+		}
+		s.notifyBeforeExit(targets, ent, fs)
+		retained = len(targets) != 0
+	} else if s.shouldNotify(ent, p.sendSlack) {
+		if targets := s.entryTargets(ent, fs, p.slackURL); len(targets) != 0 {
+			// the fields are retained by the notification.
+			retained = true
+			if job := (notifyJob{targets: targets, ent: ent, fields: fs}); !s.notifyDedup.observe(job) {
+				s.stats.NotificationDropped(DropDedup)
+			} else if !s.notifyLimit.allow(ent.Time) {
+				s.stats.NotificationDropped(DropRateLimit)
+			} else {
+				s.notifyPool.enqueue(job)
+			}
+		}
+	}
+	if s.errorReporter != nil && ent.Level >= zapcore.ErrorLevel {
+		s.errorReporter.report(ent, fields, user, terminates)
+	}
+	parent := s.parent
+	if p.audit != nil && s.auditCore != nil {
+		parent = s.auditCore
+	} else if s.router != nil {
+		if core, ok := s.router.route(fields, s.fields); ok {
+			parent = core
+		}
+	}
+	err := parent.Write(ent, fs)
+	if err == nil {
+		s.stats.EntryWritten(ent.Level, ent.LoggerName)
+	}
+	if !retained && !s.retainFields {
+		st.put()
+	}
+	switch {
+	case ent.Level == zapcore.FatalLevel && s.onFatal != nil:
+		s.onFatal(ent)
+	case ent.Level == zapcore.PanicLevel && s.onPanic != nil && !p.recovered:
+		s.onPanic(ent)
+	}
+	return err
+}
+
+// staticLabels returns the labels of m, sorted by key.
+func staticLabels(m map[string]string) labels {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lbs := make(labels, len(keys))
+	for i, k := range keys {
+		lbs[i] = zap.String(k, m[k])
 	}
-	return s.parent.Write(ent, fs)
+	return lbs
 }
 
+// traceSampled reports whether the trace of an entry, the one of its fields
+// or else of its logger, is sampled.
+func (s *stackdriver) traceSampled(fields []zapcore.Field) bool {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key != logKeyContextInfo {
+			continue
+		}
+		if info, ok := fields[i].Interface.(contextInfo); ok {
+			return info.IsSampled
+		}
+	}
+	return s.context != nil && s.context.IsSampled
+}
+
+// terminates reports whether the entries of the level terminate the process
+// once written.
+func (s *stackdriver) terminates(level zapcore.Level) bool {
+	return level >= zapcore.PanicLevel || level == zapcore.DPanicLevel && s.dpanicPanics
+}
+
+// notifyBeforeExit delivers the notification of ent to targets, then the
+// pending notifications, within slackFatalTimeout.
+func (s *stackdriver) notifyBeforeExit(targets []Notifier, ent zapcore.Entry, fields []zapcore.Field) {
+	ctx, cancel := context.WithTimeout(context.Background(), slackFatalTimeout)
+	defer cancel()
+	if len(targets) != 0 {
+		s.postNotification(ctx, targets, ent, fields)
+	}
+	s.notifyDedup.flush()
+	s.slackDigest.flush()
+	if err := s.notifyPool.drain(ctx); err != nil {
+		s.onError.errorf("zapx: failed to deliver the notifications before exit: %w", err)
+	}
+}
+
+// traceProject returns the project the traces belong to.
+func (s *stackdriver) traceProject() string {
+	if s.traceProjID != "" {
+		return s.traceProjID
+	}
+	return s.projectID
+}
+
+// traceName returns the value of logging.googleapis.com/trace, qualified by
+// the trace project, as Cloud Logging needs to correlate the entry with the
+// trace.
+func (s *stackdriver) traceName(traceID string) string {
+	project := s.traceProject()
+	if project == "" {
+		return traceID
+	}
+	return "projects/" + project + "/traces/" + traceID
+}
+
+// shouldNotify reports whether ent is notified, given the slack behavior of
+// the entry.
+func (s *stackdriver) shouldNotify(ent zapcore.Entry, send slackBehavior) bool {
+	if send == defaultSlack {
+		send = s.sendSlack
+	}
+	switch send {
+	case enableSlack:
+		return true
+	case disableSlack:
+		return false
+	}
+	return s.slackLevel != nil && ent.Level >= *s.slackLevel
+}
+
+// Sync flushes the entries, and waits for the pending notifications up to
+// the sync timeout, see WithSyncTimeout.
 func (s *stackdriver) Sync() error {
-	s.slackWG.Wait()
-	return s.parent.Sync()
+	ctx := context.Background()
+	if d := s.notifyPool.syncTimeout; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	return s.sync(ctx)
+}
+
+// sync is Sync, waiting for the pending notifications until ctx is done.
+func (s *stackdriver) sync(ctx context.Context) error {
+	var err error
+	if s.deduper != nil {
+		if flush := s.deduper.flush(); flush != nil {
+			err = flush.write()
+		}
+	}
+	s.notifyDedup.flush()
+	err = multierr.Append(err, s.notifyPool.drain(ctx))
+	if s.slackDigest != nil {
+		s.slackDigest.flush()
+		err = multierr.Append(err, s.notifyPool.drain(ctx))
+	}
+	s.errorReporter.flush()
+	if s.router != nil {
+		err = multierr.Append(err, s.router.sync())
+	}
+	if s.auditCore != nil {
+		err = multierr.Append(err, s.auditCore.Sync())
+	}
+	return multierr.Append(err, s.parent.Sync())
 }
 
-func (s *stackdriver) parseFields(fields []zapcore.Field, msg ...string) (fs []zapcore.Field, user string, sendSlack slackBehavior, slackURL string) {
-	labels := labels([]zap.Field{})
+// parsedFields is the result of parseFields.
+type parsedFields struct {
+	// fields are the top level fields.
+	fields []zapcore.Field
+	// nested are the fields following a zap.Namespace.
+	nested    []zapcore.Field
+	labels    labels
+	metrics   labels
+	user      string
+	sendSlack slackBehavior
+	slackURL  string
+	minimal   bool
+	// recovered is set for the panics recovered, see Recover.
+	recovered bool
+	// context is the last trace found, see Context.
+	context *contextInfo
+	// audit is the payload of an audit entry, see Audit.
+	audit *auditLog
+}
+
+// contextFields returns the fields of the trace info.
+func (s *stackdriver) contextFields(info contextInfo) []zapcore.Field {
+	var fs []zapcore.Field
+	if s.rawSpanID && info.RawSpanID != "" {
+		info.SpanID = info.RawSpanID
+	}
+	if info.IsSampled || (s.alwaysTrace && info.TraceID != "") {
+		fs = append(fs,
+			zap.Bool("logging.googleapis.com/trace_sampled", info.IsSampled),
+			zap.String("logging.googleapis.com/trace", s.traceName(info.TraceID)),
+			zap.String("logging.googleapis.com/spanId", info.SpanID),
+		)
+	}
+	if project := s.traceProject(); s.traceLinks && project != "" && info.TraceID != "" {
+		fs = append(fs, zap.String("trace_url", traceURL(project, info.TraceID)))
+	}
+	if info.GrpcMethod != "" {
+		fs = append(fs, zap.String("grpc_method", info.GrpcMethod))
+	}
+	if id := info.requestID(s.requestIDKeys); id != "" && !info.requestIDLabel {
+		fs = append(fs, zap.String("request_id", id))
+	}
+	return fs
+}
+
+// parseFields resolves the special fields, the others being appended to buf.
+// The fields following a zap.Namespace, either in fields or attached with
+// With, are returned separately as nested, so that the fields promoted by the
+// core, e.g. labels and trace, remain at the top level of the entry.
+func (s *stackdriver) parseFields(buf, fields []zapcore.Field, msg ...string) parsedFields {
+	var (
+		fs        = buf[:0]
+		nested    []zapcore.Field
+		labels    labels
+		metrics   []zapcore.Field
+		user      string
+		sendSlack slackBehavior
+		slackURL  string
+		minimal   bool
+		recovered bool
+		ctxInfo   *contextInfo
+		audit     *auditLog
+	)
+	out := &fs
+	if len(s.nested) != 0 {
+		out = &nested
+	}
 	for _, f := range fields {
+		if f.Type == zapcore.NamespaceType && out == &fs {
+			out = &nested
+		}
 		if strings.HasPrefix(f.Key, logKeyLabelPrefix) {
 			key := strings.TrimPrefix(f.Key, logKeyLabelPrefix)
 			val := f.String
 			labels = append(labels, zap.String(key, val))
 			continue
 		}
+		if strings.HasPrefix(f.Key, logKeyMetricPrefix) {
+			f.Key = strings.TrimPrefix(f.Key, logKeyMetricPrefix)
+			metrics = append(metrics, f)
+			continue
+		}
 		switch f.Key {
 
+		case logKeyThrottle, logKeyCanonicalLine:
+			// handled by write
+		case logKeyMinimal:
+			minimal = true
+		case logKeyRecovered:
+			recovered = true
+		case logKeyErrorClass:
+			if c, ok := f.Interface.(errorClass); ok {
+				labels = append(labels, c.labels()...)
+			}
+		case logKeyK8sObject:
+			if o, ok := f.Interface.(k8sObject); ok {
+				labels = append(labels, o.labels()...)
+				*out = append(*out, zap.Object(logKeyK8sObjectPayload, o))
+			}
+		case logKeyEvent:
+			if ev, ok := f.Interface.(TypedEvent); ok {
+				labels = append(labels, zap.String(eventNameLabel, ev.EventName()))
+				*out = append(*out, zap.Object(logKeyEventPayload, ev))
+				if reason := validateEvent(ev); reason != "" {
+					*out = append(*out, zap.String("event.invalid", reason))
+				}
+			}
 		case "user":
 			if f.Type == zapcore.StringType {
 				user = f.String
 			}
+		case logKeyAudit:
+			if a, ok := f.Interface.(auditLog); ok {
+				audit = &a
+			}
+		case logKeyLogID:
+			if f.Type == zapcore.StringType {
+				labels = append(labels, zap.String("log_id", f.String))
+			}
 		case "stack_trace":
 			if f.Type == zapcore.StringType && len(msg) > 0 {
 				f.String = msg[0] + "\n" + f.String
@@ -219,26 +1135,13 @@ func (s *stackdriver) parseFields(fields []zapcore.Field, msg ...string) (fs []z
 			fs = append(fs, f)
 		case logKeyContextInfo:
 			if info, ok := f.Interface.(contextInfo); ok {
-				if info.IsSampled {
-					fs = append(fs,
-						zap.Bool("logging.googleapis.com/trace_sampled", true),
-						zap.String("logging.googleapis.com/trace", info.TraceID),
-						zap.String("logging.googleapis.com/spanId", info.SpanID),
-					)
-				}
-				if info.GrpcMethod != "" {
-					fs = append(fs, zap.String("grpc_method", info.GrpcMethod))
-				}
-				if info.RequestID != "" {
-					fs = append(fs, zap.String("request_id", info.RequestID))
-				}
+				ctxInfo = &info
 			}
 
 		case logKeySlackNotification:
 			if f.Type == zapcore.BoolType {
 				if f.Integer == 1 {
 					sendSlack = enableSlack
-					slackURL = s.slackURL
 				} else {
 					sendSlack = disableSlack
 				}
@@ -247,20 +1150,75 @@ func (s *stackdriver) parseFields(fields []zapcore.Field, msg ...string) (fs []z
 				slackURL = f.String
 			}
 		default:
+			if req, ok := f.Interface.(HTTPRequestEntry); ok && s.reqRedaction != nil {
+				*out = append(*out, zap.Object(f.Key, s.reqRedaction.entry(req)))
+				if req.Request != nil {
+					if h := s.reqRedaction.headerObject(req.Request.Header); h != nil {
+						*out = append(*out, zap.Object("requestHeaders", h))
+					}
+				}
+				break
+			}
+			if md, ok := f.Interface.(wmetadata); ok {
+				if f.Key == "metadata" && len(s.mdLabels) != 0 {
+					labels = append(labels, s.mdLabels.labels(md)...)
+					if s.mdDropRest {
+						break
+					}
+				}
+				if s.mdMaxValue > 0 || s.mdMaxTotal > 0 {
+					*out = append(*out, zap.Object(f.Key, limitedMetadata{md: md, maxValue: s.mdMaxValue, maxTotal: s.mdMaxTotal}))
+					break
+				}
+			}
+			if m, ok := f.Interface.(*jsonpbObjectMarshaler); ok && (s.protoTypes != nil || s.protoMax > 0) {
+				resolver, maxBytes := m.resolver, m.maxBytes
+				if resolver == nil {
+					resolver = s.protoTypes
+				}
+				if maxBytes == 0 {
+					maxBytes = s.protoMax
+				}
+				*out = append(*out, zap.Reflect(f.Key, newJSONPBObjectMarshaler(m.pb, resolver, maxBytes)))
+				break
+			}
+			if s.errorChain && f.Type == zapcore.ErrorType {
+				if err, ok := f.Interface.(error); ok {
+					*out = append(*out, NamedErrorChain(f.Key+"_chain", err))
+				}
+			}
+			// render the errors joined one by one
+			if f.Type == zapcore.ErrorType {
+				if err, ok := f.Interface.(error); ok {
+					if errs := splitErrors(err); len(errs) > 1 {
+						*out = append(*out, zap.Object(f.Key, multiError{err: err, errs: errs, parse: s.errorPraser}))
+						break
+					}
+				}
+			}
 			// customize error parsing
 			if s.errorPraser != nil && f.Type == zapcore.ErrorType {
 				if err, ok := f.Interface.(error); ok {
 					if obj, ok := s.errorPraser(err); ok {
-						fs = append(fs, zap.Object(f.Key, obj))
+						*out = append(*out, zap.Object(f.Key, obj))
 						break
 					}
 				}
 			}
-			fs = append(fs, f)
+			*out = append(*out, f)
 		}
 	}
-	if len(labels) != 0 {
-		fs = append(fs, zap.Object("logging.googleapis.com/labels", labels))
+	return parsedFields{
+		fields:    fs,
+		nested:    nested,
+		labels:    labels,
+		metrics:   metrics,
+		user:      user,
+		sendSlack: sendSlack,
+		slackURL:  slackURL,
+		minimal:   minimal,
+		recovered: recovered,
+		context:   ctxInfo,
+		audit:     audit,
 	}
-	return fs, user, sendSlack, slackURL
 }
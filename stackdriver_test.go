@@ -0,0 +1,39 @@
+package zapx
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/metadata"
+)
+
+func BenchmarkWrite(b *testing.B) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-cloud-trace-context", "105445aa7843bc8bf206b12000100000/1;o=1",
+		"x-request-id", "req-1",
+	))
+	err := errors.New("boom")
+	benchmarks := []struct {
+		name   string
+		fields []zapcore.Field
+	}{
+		{"plain", []zapcore.Field{zap.Int("n", 1)}},
+		{"labels", []zapcore.Field{zap.Int("n", 1), Label("tenant", "a"), Label("region", "b")}},
+		{"context", []zapcore.Field{zap.Int("n", 1), Context(ctx)}},
+		{"error", []zapcore.Field{zap.Int("n", 1), zap.Error(err)}},
+		{"all", []zapcore.Field{zap.Int("n", 1), Label("tenant", "a"), Context(ctx), zap.Error(err)}},
+	}
+	logger := Zap(zap.DebugLevel, WithOutput(zapcore.AddSync(ioutil.Discard)))
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.Info("message", bm.fields...)
+			}
+		})
+	}
+}
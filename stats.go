@@ -0,0 +1,55 @@
+package zapx
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Stats receives the events of a logger, e.g. to export them as metrics, see
+// WithStats. The methods are called synchronously by the logger and the
+// notification workers, and must be safe for concurrent use.
+type Stats interface {
+	// EntryWritten is called for every entry written.
+	EntryWritten(level zapcore.Level, logger string)
+	// EntryDropped is called for every entry dropped, by sampling,
+	// throttling or deduplication.
+	EntryDropped(level zapcore.Level, reason string)
+	// NotificationDelivered is called once the delivery of a notification
+	// to notifier is over, after attempts attempts, with the error of the
+	// last one.
+	NotificationDelivered(notifier string, attempts int, latency time.Duration, err error)
+	// NotificationDropped is called for every notification dropped before
+	// its delivery, by deduplication, rate limiting, queue overflow or the
+	// closing of the logger.
+	NotificationDropped(reason string)
+}
+
+// The reasons for which the entries and notifications are dropped.
+const (
+	DropSampling  = "sampling"
+	DropThrottle  = "throttle"
+	DropDedup     = "dedup"
+	DropRateLimit = "rate_limit"
+	DropQueueFull = "queue_full"
+	DropClosed    = "closed"
+)
+
+type nopStats struct{}
+
+func (nopStats) EntryWritten(zapcore.Level, string)                      {}
+func (nopStats) EntryDropped(zapcore.Level, string)                      {}
+func (nopStats) NotificationDelivered(string, int, time.Duration, error) {}
+func (nopStats) NotificationDropped(string)                              {}
+
+// notifierName returns the name of n for the stats: its Name() if it has
+// one, or its type, e.g. "slackNotifier".
+func notifierName(n Notifier) string {
+	if named, ok := n.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	name := fmt.Sprintf("%T", n)
+	return name[strings.LastIndex(name, ".")+1:]
+}
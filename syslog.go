@@ -0,0 +1,158 @@
+package zapx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// syslogFacility is the facility of the messages, user-level.
+const syslogFacility = 1
+
+// syslogSeverities are the syslog severities of the stackdriver severities.
+var syslogSeverities = map[string]int{
+	"EMERGENCY": 0,
+	"ALERT":     1,
+	"CRITICAL":  2,
+	"ERROR":     3,
+	"WARNING":   4,
+	"INFO":      6,
+	"DEBUG":     7,
+}
+
+// syslogSink is a zapcore.WriteSyncer sending the entries encoded by the core
+// to a syslog server as RFC 5424 messages, the entry being the message.
+type syslogSink struct {
+	network, raddr string
+	hostname       string
+	tag            string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSyslogSink(network, raddr, tag string) (*syslogSink, error) {
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	if tag == "" {
+		tag = "-"
+	}
+	s := &syslogSink{network: network, raddr: raddr, hostname: hostname, tag: tag}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect dials the server, or the local syslog daemon if no network is set.
+func (s *syslogSink) connect() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.network != "" {
+		conn, err := net.Dial(s.network, s.raddr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		return nil
+	}
+	for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				s.network, s.raddr, s.conn = network, path, conn
+				return nil
+			}
+		}
+	}
+	return errors.New("zapx: no local syslog daemon found")
+}
+
+// Write sends the entry encoded in p, reconnecting once on failure.
+func (s *syslogSink) Write(p []byte) (int, error) {
+	var head struct {
+		Severity  string `json:"severity"`
+		EventTime string `json:"eventTime"`
+	}
+	if err := json.Unmarshal(p, &head); err != nil {
+		return 0, err
+	}
+	severity, ok := syslogSeverities[head.Severity]
+	if !ok {
+		severity = syslogSeverities["INFO"]
+	}
+	ts, err := parseEventTime(head.EventTime)
+	if err != nil {
+		ts = time.Now()
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(syslogFacility*8 + severity))
+	b.WriteString(">1 ")
+	b.WriteString(ts.Format("2006-01-02T15:04:05.000000Z07:00"))
+	b.WriteByte(' ')
+	b.WriteString(s.hostname)
+	b.WriteByte(' ')
+	b.WriteString(s.tag)
+	b.WriteByte(' ')
+	b.WriteString(strconv.Itoa(os.Getpid()))
+	b.WriteString(" - - ")
+	b.Write(bytes.TrimRight(p, "\n"))
+	msg := b.Bytes()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				continue
+			}
+		}
+		if err = s.send(msg); err == nil {
+			break
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// send writes msg on the connection, framed by octet counting on streams,
+// see RFC 6587.
+func (s *syslogSink) send(msg []byte) error {
+	switch s.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// Sync is a no-op, the messages are not buffered.
+func (s *syslogSink) Sync() error {
+	return nil
+}
+
+// Close closes the connection to the server.
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,104 @@
+package zapx
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const logKeyThrottle = "zapx.throttle"
+
+type throttleSpec struct {
+	key      string
+	interval time.Duration
+}
+
+// Throttle constructs a field that limits the entries sharing the same key to
+// at most one per interval. Entries dropped in between are counted, and the
+// count is attached as "suppressed" to the next entry that gets through.
+func Throttle(key string, interval time.Duration) zapcore.Field {
+	return zap.Reflect(logKeyThrottle, throttleSpec{key: key, interval: interval})
+}
+
+type throttleState struct {
+	last       time.Time
+	interval   time.Duration
+	suppressed int64
+}
+
+const (
+	// throttleSweep is the number of sites from which the throttler sweeps
+	// the sites whose interval has passed.
+	throttleSweep = 256
+	// maxThrottleSites is the number of sites above which the sweep forgets
+	// the suppressed counts of the sites whose interval has passed.
+	maxThrottleSites = 4096
+)
+
+// throttler keeps track of the throttled log sites of a logger and all of its
+// children.
+type throttler struct {
+	mu    sync.Mutex
+	sites map[string]*throttleState
+	// sweepAt is the number of sites of the next sweep.
+	sweepAt int
+}
+
+func newThrottler() *throttler {
+	return &throttler{sites: make(map[string]*throttleState), sweepAt: throttleSweep}
+}
+
+// allow reports whether an entry of the given spec may be written at now, and
+// how many entries were suppressed since the last one.
+func (t *throttler) allow(spec throttleSpec, now time.Time) (bool, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.sites[spec.key]
+	if !ok {
+		if len(t.sites) >= t.sweepAt {
+			t.sweep(now)
+		}
+		t.sites[spec.key] = &throttleState{last: now, interval: spec.interval}
+		return true, 0
+	}
+	st.interval = spec.interval
+	if now.Sub(st.last) < spec.interval {
+		st.suppressed++
+		return false, 0
+	}
+	n := st.suppressed
+	st.last = now
+	st.suppressed = 0
+	return true, n
+}
+
+// sweep forgets the sites whose interval has passed without suppressed
+// entries, as their next entry would be written as the one of a new site.
+// Above maxThrottleSites, the sites whose interval has passed are forgotten
+// along with their suppressed count.
+func (t *throttler) sweep(now time.Time) {
+	all := len(t.sites) >= maxThrottleSites
+	for key, st := range t.sites {
+		if now.Sub(st.last) >= st.interval && (st.suppressed == 0 || all) {
+			delete(t.sites, key)
+		}
+	}
+	t.sweepAt = 2 * len(t.sites)
+	if t.sweepAt < throttleSweep {
+		t.sweepAt = throttleSweep
+	}
+}
+
+func findThrottle(fields []zapcore.Field) (throttleSpec, bool) {
+	for _, f := range fields {
+		if f.Key != logKeyThrottle {
+			continue
+		}
+		if spec, ok := f.Interface.(throttleSpec); ok {
+			return spec, true
+		}
+	}
+	return throttleSpec{}, false
+}
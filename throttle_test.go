@@ -0,0 +1,84 @@
+package zapx
+
+import (
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestThrottle(t *testing.T) {
+	tests := []struct {
+		name string
+		// gaps are the times elapsed before each entry.
+		gaps []time.Duration
+		// want are the suppressed counts of the entries written.
+		want []int64
+	}{
+		{"first entry", []time.Duration{0}, []int64{0}},
+		{"burst suppressed", []time.Duration{0, time.Second, time.Second}, []int64{0}},
+		{"count attached to the next entry", []time.Duration{0, time.Second, time.Second, time.Minute}, []int64{0, 2}},
+		{"spaced entries", []time.Duration{0, time.Minute, time.Minute}, []int64{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			obs, logs := observer.New(zapcore.DebugLevel)
+			logger := Zap(zapcore.DebugLevel,
+				WithOutput(zapcore.AddSync(ioutil.Discard)),
+				WithCores(obs),
+				WithClock(clock),
+			)
+			for _, gap := range tt.gaps {
+				clock.Add(gap)
+				logger.Info("retrying", Throttle("retry", time.Minute))
+			}
+			entries := logs.AllUntimed()
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(tt.want))
+			}
+			for i, e := range entries {
+				got, _ := e.ContextMap()["suppressed"].(int64)
+				if got != tt.want[i] {
+					t.Errorf("entry %d: suppressed = %d, want %d", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestThrottlerSweep(t *testing.T) {
+	tests := []struct {
+		name       string
+		sites      int
+		suppressed bool
+		// want is the number of sites left once a new site is added after
+		// the interval.
+		want int
+	}{
+		{"below the sweep", throttleSweep - 1, false, throttleSweep},
+		{"expired sites forgotten", throttleSweep, false, 1},
+		{"suppressed counts kept", throttleSweep, true, throttleSweep + 1},
+		{"suppressed counts forgotten above the cap", maxThrottleSites, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newThrottler()
+			now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < tt.sites; i++ {
+				spec := throttleSpec{key: strconv.Itoa(i), interval: time.Minute}
+				th.allow(spec, now)
+				if tt.suppressed {
+					th.allow(spec, now)
+				}
+			}
+			th.allow(throttleSpec{key: "new", interval: time.Minute}, now.Add(time.Minute))
+			if got := len(th.sites); got != tt.want {
+				t.Errorf("%d sites left, want %d", got, tt.want)
+			}
+		})
+	}
+}
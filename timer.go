@@ -0,0 +1,71 @@
+package zapx
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Stopwatch measures the time elapsed since it was started by Timer.
+type Stopwatch struct {
+	key   string
+	start time.Time
+
+	mu      sync.Mutex
+	stopped bool
+	elapsed time.Duration
+}
+
+// Timer starts a stopwatch whose Field records the elapsed time under key.
+// Unless Stop has been called, the elapsed time is taken when the entry is
+// written.
+func Timer(key string) *Stopwatch {
+	return &Stopwatch{key: key, start: time.Now()}
+}
+
+// Stop freezes the stopwatch and returns the elapsed time. Subsequent calls
+// return the same value.
+func (s *Stopwatch) Stop() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		s.stopped = true
+		s.elapsed = time.Since(s.start)
+	}
+	return s.elapsed
+}
+
+// Elapsed returns the elapsed time without stopping the stopwatch.
+func (s *Stopwatch) Elapsed() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return s.elapsed
+	}
+	return time.Since(s.start)
+}
+
+// Field constructs a field that carries the elapsed time.
+func (s *Stopwatch) Field() zapcore.Field {
+	return zap.Stringer(s.key, s)
+}
+
+// String implements fmt.Stringer, used when the field is encoded by a core
+// other than zapx's.
+func (s *Stopwatch) String() string {
+	return s.Elapsed().String()
+}
+
+// resolveTimers replaces the stopwatch fields in fs with their elapsed time.
+func resolveTimers(fs []zapcore.Field) {
+	for i, f := range fs {
+		if f.Type != zapcore.StringerType {
+			continue
+		}
+		if sw, ok := f.Interface.(*Stopwatch); ok {
+			fs[i] = zap.Duration(f.Key, sw.Elapsed())
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package zapx
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// traceParsers extract the trace information from the propagation headers,
+// in order of preference.
+var traceParsers = []func(md metadata.MD, info *contextInfo) bool{
+	parseCloudTraceContext,
+	parseTraceparent,
+	parseXRayTraceID,
+	parseUberTraceID,
+	parseB3,
+}
+
+// traceFromMetadata fills info with the first trace found in md.
+func traceFromMetadata(md metadata.MD, info *contextInfo) bool {
+	for _, parse := range traceParsers {
+		if parse(md, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCloudTraceContext parses the x-cloud-trace-context header, in the form
+// of TRACE_ID/SPAN_ID;o=TRACE_TRUE.
+func parseCloudTraceContext(md metadata.MD, info *contextInfo) bool {
+	vals := md.Get("x-cloud-trace-context")
+	if len(vals) == 0 {
+		return false
+	}
+	h := vals[0]
+	slash := strings.Index(h, `/`)
+	if slash == -1 {
+		return false
+	}
+	tid, h := h[:slash], h[slash+1:]
+	info.TraceID = tid
+	// Parse the span id field.
+	spanstr := h
+	semicolon := strings.Index(h, `;`)
+	if semicolon != -1 {
+		spanstr, h = h[:semicolon], h[semicolon+1:]
+	}
+	info.SpanID = normalizeSpanID(spanstr)
+	if info.SpanID != spanstr {
+		info.RawSpanID = spanstr
+	}
+	info.IsSampled = strings.HasPrefix(h, "o=1")
+	return true
+}
+
+// parseTraceparent parses the W3C traceparent header, in the form of
+// {version}-{trace-id}-{parent-id}-{trace-flags}.
+func parseTraceparent(md metadata.MD, info *contextInfo) bool {
+	vals := md.Get("traceparent")
+	if len(vals) == 0 {
+		return false
+	}
+	parts := strings.Split(strings.TrimSpace(vals[0]), "-")
+	if len(parts) < 4 || !isHex(parts[0], 2) || parts[0] == "ff" || !isHex(parts[1], 32) || !isHex(parts[2], 16) {
+		return false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return false
+	}
+	info.TraceID = parts[1]
+	info.SpanID = parts[2]
+	info.IsSampled = flags&0x01 != 0
+	return true
+}
+
+// parseXRayTraceID parses the AWS X-Ray x-amzn-trace-id header, in the form
+// of Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1.
+// The root is converted to the 32-char hex form of the other formats.
+func parseXRayTraceID(md metadata.MD, info *contextInfo) bool {
+	vals := md.Get("x-amzn-trace-id")
+	if len(vals) == 0 {
+		return false
+	}
+	var root, parent, sampled string
+	for _, kv := range strings.Split(vals[0], ";") {
+		eq := strings.Index(kv, "=")
+		if eq == -1 {
+			continue
+		}
+		switch strings.TrimSpace(kv[:eq]) {
+		case "Root":
+			root = kv[eq+1:]
+		case "Parent":
+			parent = kv[eq+1:]
+		case "Sampled":
+			sampled = kv[eq+1:]
+		}
+	}
+	parts := strings.Split(root, "-")
+	if len(parts) != 3 || parts[0] != "1" {
+		return false
+	}
+	info.TraceID = parts[1] + parts[2]
+	info.SpanID = parent
+	info.IsSampled = sampled == "1"
+	return true
+}
+
+// parseUberTraceID parses the Jaeger uber-trace-id header, in the form of
+// {trace-id}:{span-id}:{parent-span-id}:{flags}. The debug flag forces the
+// sampling.
+func parseUberTraceID(md metadata.MD, info *contextInfo) bool {
+	vals := md.Get("uber-trace-id")
+	if len(vals) == 0 {
+		return false
+	}
+	h := strings.ReplaceAll(vals[0], "%3A", ":")
+	parts := strings.Split(h, ":")
+	if len(parts) != 4 || parts[0] == "" || len(parts[0]) > 32 || parts[1] == "" || len(parts[1]) > 16 {
+		return false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return false
+	}
+	info.TraceID = leftPad(parts[0], 32)
+	info.SpanID = leftPad(parts[1], 16)
+	// 0x01 is sampled, 0x02 is debug
+	info.IsSampled = flags&0x03 != 0
+	return true
+}
+
+// parseB3 parses the Zipkin B3 headers, either the single b3 header, in the
+// form of {trace-id}-{span-id}-{sampling-state}-{parent-span-id}, or the
+// x-b3-traceid, x-b3-spanid, x-b3-sampled and x-b3-flags headers. The 64-bit
+// trace ids are padded to 128 bits, and the debug state forces the sampling.
+func parseB3(md metadata.MD, info *contextInfo) bool {
+	var traceID, spanID, sampled string
+	if vals := md.Get("b3"); len(vals) != 0 {
+		parts := strings.Split(vals[0], "-")
+		if len(parts) < 2 {
+			return false
+		}
+		traceID, spanID = parts[0], parts[1]
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+	} else {
+		traceID, spanID = first(md.Get("x-b3-traceid")), first(md.Get("x-b3-spanid"))
+		sampled = first(md.Get("x-b3-sampled"))
+		if first(md.Get("x-b3-flags")) == "1" {
+			sampled = "d"
+		}
+	}
+	if !(isHex(traceID, 16) || isHex(traceID, 32)) || !isHex(spanID, 16) {
+		return false
+	}
+	info.TraceID = leftPad(traceID, 32)
+	info.SpanID = spanID
+	info.IsSampled = sampled == "1" || sampled == "true" || sampled == "d"
+	return true
+}
+
+func first(vals []string) string {
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+func leftPad(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return strings.Repeat("0", n-len(s)) + s
+}
+
+// normalizeSpanID converts the decimal span id of x-cloud-trace-context into
+// the 16-char hex form used by Cloud Logging and Cloud Trace.
+func normalizeSpanID(id string) string {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return id
+	}
+	return fmt.Sprintf("%016x", n)
+}
+
+// traceURL returns the Cloud Trace console link of the trace.
+func traceURL(projectID, traceID string) string {
+	return "https://console.cloud.google.com/traces/list?project=" + url.QueryEscape(projectID) + "&tid=" + url.QueryEscape(traceID)
+}
@@ -0,0 +1,109 @@
+package zapx
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TransportOption configures Transport.
+type TransportOption func(*transport)
+
+// SlowThreshold logs the calls taking longer than d at warn level at least,
+// with "slow": true.
+func SlowThreshold(d time.Duration) TransportOption {
+	return func(t *transport) {
+		t.slow = d
+	}
+}
+
+// transport is the http.RoundTripper of Transport.
+type transport struct {
+	base   http.RoundTripper
+	logger *zap.Logger
+	keys   []string
+	slow   time.Duration
+}
+
+// Transport returns an http.RoundTripper logging every outgoing request sent
+// by base, http.DefaultTransport if nil, as an httpRequest entry, see Request,
+// with the trace of the context of the request, see Context: at error level
+// if it failed or for 5xx responses, at warn level for 4xx and at info level
+// otherwise. The trace and the request id of the context are propagated in
+// the headers of the request, as InjectHTTP does, unless set already.
+func Transport(base http.RoundTripper, logger *zap.Logger, opts ...TransportOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{base: base, logger: logger}
+	if s, ok := coreOf(logger); ok {
+		t.keys = s.requestIDKeys
+	}
+	for _, o := range opts {
+		o(t)
+	}
+	return t
+}
+
+// RoundTrip is http.RoundTripper implementation.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	var serverIP string
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			serverIP = info.Conn.RemoteAddr().String()
+		},
+	})
+	out := req.Clone(ctx)
+	for key, val := range propagationHeaders(contextInfoFrom(ctx), t.keys) {
+		if out.Header.Get(key) == "" {
+			out.Header.Set(key, val)
+		}
+	}
+
+	start := time.Now()
+	res, err := t.base.RoundTrip(out)
+	latency := time.Since(start)
+
+	entry := HTTPRequestEntry{
+		Request:    req,
+		RequestURL: req.URL.Redacted(),
+		Latency:    latency,
+		ServerIP:   serverIP,
+	}
+	if req.ContentLength > 0 {
+		entry.RequestSize = req.ContentLength
+	}
+	level := zapcore.InfoLevel
+	if err != nil {
+		level = zapcore.ErrorLevel
+	} else {
+		entry.Status = res.StatusCode
+		entry.Protocol = res.Proto
+		if res.ContentLength > 0 {
+			entry.ResponseSize = res.ContentLength
+		}
+		switch {
+		case res.StatusCode >= 500:
+			level = zapcore.ErrorLevel
+		case res.StatusCode >= 400:
+			level = zapcore.WarnLevel
+		}
+	}
+	slow := t.slow > 0 && latency > t.slow
+	if slow && level < zapcore.WarnLevel {
+		level = zapcore.WarnLevel
+	}
+	if ce := t.logger.Check(level, req.Method+" "+req.URL.Redacted()); ce != nil {
+		ce.Write(
+			Context(req.Context()),
+			Request(entry),
+			If(slow, zap.Bool("slow", true)),
+			If(err != nil, zap.Error(err)),
+		)
+	}
+	return res, err
+}
@@ -0,0 +1,118 @@
+package zapx
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// truncatedKeepKeys are the fields never truncated, as Cloud Logging needs
+// them to index and correlate the entry.
+var truncatedKeepKeys = map[string]bool{
+	"logging.googleapis.com/trace":          true,
+	"logging.googleapis.com/spanId":         true,
+	"logging.googleapis.com/trace_sampled":  true,
+	"logging.googleapis.com/labels":         true,
+	"logging.googleapis.com/sourceLocation": true,
+	logKeyOperation:                         true,
+	"serviceContext":                        true,
+	"context":                               true,
+	"resource":                              true,
+	"httpRequest":                           true,
+}
+
+// truncatedMinField is the size below which the fields are not truncated.
+const truncatedMinField = 256
+
+// entryTruncator keeps the entries under a maximum encoded size, see
+// WithMaxEntrySize.
+type entryTruncator struct {
+	enc      zapcore.Encoder
+	maxBytes int
+}
+
+// truncate returns ent and fields, with the message and the largest fields
+// truncated if the entry encoded exceeds the maximum size, and whether they
+// were.
+func (t *entryTruncator) truncate(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+	size := t.size(ent, fields)
+	if size <= t.maxBytes {
+		return ent, fields, false
+	}
+	// share the budget among the large fields, and the message.
+	type candidate struct {
+		i    int
+		size int
+	}
+	var candidates []candidate
+	for i, f := range fields {
+		if truncatedKeepKeys[f.Key] || f.Type == zapcore.NamespaceType || f.Type == zapcore.SkipType {
+			continue
+		}
+		if n := t.size(zapcore.Entry{}, fields[i:i+1]); n > truncatedMinField {
+			candidates = append(candidates, candidate{i: i, size: n})
+		}
+	}
+	budget := t.maxBytes / (len(candidates) + 2)
+	if budget < truncatedMinField {
+		budget = truncatedMinField
+	}
+	ent.Message = truncateString(ent.Message, budget)
+	out := append([]zapcore.Field(nil), fields...)
+	for _, c := range candidates {
+		if c.size > budget {
+			out[c.i] = truncateField(out[c.i], budget)
+		}
+	}
+	if t.size(ent, out) > t.maxBytes {
+		// still too large, e.g. too many fields, keep their head only.
+		for _, c := range candidates {
+			out[c.i] = truncateField(out[c.i], truncatedMinField/2)
+		}
+	}
+	return ent, out, true
+}
+
+// size returns the size of the entry encoded.
+func (t *entryTruncator) size(ent zapcore.Entry, fields []zapcore.Field) int {
+	buf, err := t.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return 0
+	}
+	n := buf.Len()
+	buf.Free()
+	return n
+}
+
+// truncateField returns a string field with the head of the value of f.
+func truncateField(f zapcore.Field, n int) zapcore.Field {
+	var s string
+	switch f.Type {
+	case zapcore.StringType:
+		s = f.String
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			s = err.Error()
+		}
+	default:
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		if buf, err := json.Marshal(enc.Fields[f.Key]); err == nil {
+			s = string(buf)
+		}
+	}
+	return zap.String(f.Key, truncateString(s, n))
+}
+
+// markTruncated returns fields with the zapx_truncated label.
+func markTruncated(fields []zapcore.Field) []zapcore.Field {
+	mark := labels{zap.String("zapx_truncated", "true")}
+	for i, f := range fields {
+		if lbs, ok := f.Interface.(labels); ok && f.Key == "logging.googleapis.com/labels" {
+			fields[i] = zap.Object(f.Key, lbs.merge(mark))
+			return fields
+		}
+	}
+	return append(fields, zap.Object("logging.googleapis.com/labels", mark))
+}
@@ -1,20 +1,22 @@
 package zapx
 
 import (
-	"context"
+	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc/metadata"
 )
 
-func reportLocationFromEntry(ent zapcore.Entry) reportLocation {
+func reportLocationFromEntry(ent zapcore.Entry, path callerPath) reportLocation {
 	caller := ent.Caller
 
 	if !caller.Defined {
 		return reportLocation{}
 	}
 	loc := reportLocation{
-		filePath:     caller.TrimmedPath(),
+		filePath:     path(caller),
 		lineNumber:   caller.Line,
 		functionName: caller.Function,
 	}
@@ -22,27 +24,88 @@ func reportLocationFromEntry(ent zapcore.Entry) reportLocation {
 	return loc
 }
 
-func sourceLocationFromEntry(ent zapcore.Entry) sourceLocation {
-	caller := ent.Caller
+// requestIDKeys returns keys, or the default metadata key of the request ids,
+// see WithRequestIDKeys.
+func requestIDKeys(keys []string) []string {
+	if len(keys) == 0 {
+		return []string{RequestIDMetadataKey}
+	}
+	return keys
+}
 
-	if !caller.Defined {
-		return sourceLocation{}
+// requestIDFromMetadata returns the request id of md under the first of keys
+// found.
+func requestIDFromMetadata(md metadata.MD, keys []string) string {
+	for _, key := range requestIDKeys(keys) {
+		if ids := md.Get(key); len(ids) != 0 && ids[0] != "" {
+			return ids[0]
+		}
 	}
-	loc := sourceLocation{
-		file:     caller.TrimmedPath(),
-		line:     caller.Line,
-		function: caller.Function,
+	return ""
+}
+
+// panicStack converts a stack trace captured by zap into the format of a Go
+// panic, i.e. with a goroutine header following a blank line and the function lines ending with
+// their arguments.
+func panicStack(stack string) string {
+	var b strings.Builder
+	b.WriteString("\ngoroutine 1 [running]:\n")
+	for _, line := range strings.Split(stack, "\n") {
+		if line == "" {
+			continue
+		}
+		b.WriteString(line)
+		if !strings.HasPrefix(line, "\t") && !strings.HasSuffix(line, ")") {
+			b.WriteString("(...)")
+		}
+		b.WriteByte('\n')
 	}
+	return b.String()
+}
 
-	return loc
+// hasField reports whether fields has a field named key.
+func hasField(fields []zapcore.Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
 }
 
-func extractRequestID(ctx context.Context) string {
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		reqIDs, ok := md[RequestIDMetadataKey]
-		if ok && len(reqIDs) > 0 {
-			return reqIDs[0]
+// afterFunc calls f in its own goroutine once d has elapsed on clock, unless
+// the returned stop is called before.
+func afterFunc(clock zapcore.Clock, d time.Duration, f func()) (stop func()) {
+	if d <= 0 {
+		// NewTicker panics on non-positive durations.
+		d = time.Nanosecond
+	}
+	ticker := clock.NewTicker(d)
+	done := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		select {
+		case <-ticker.C:
+			select {
+			case <-done:
+				// stopped as the ticker fired
+			default:
+				f()
+			}
+		case <-done:
 		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
 	}
-	return ""
+}
+
+// parseEventTime parses the time of an entry encoded by the core, with
+// zapcore.ISO8601TimeEncoder or an RFC 3339 encoder.
+func parseEventTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02T15:04:05.000Z0700", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
 }
@@ -0,0 +1,101 @@
+package zapx
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a zapcore.Clock advanced by hand, see Add.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	c    chan time.Time
+	d    time.Duration
+	next time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) *time.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{c: make(chan time.Time, 1), d: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return &time.Ticker{C: t.c}
+}
+
+// Add advances the clock by d, firing the tickers due.
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.d)
+		}
+	}
+}
+
+// eventually fails the test unless cond holds within a second.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAfterFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		advance time.Duration
+		stop    bool
+		want    bool
+	}{
+		{"fires once elapsed", time.Second, false, true},
+		{"waits until elapsed", time.Second - 1, false, false},
+		{"stopped", time.Second, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			fired := make(chan struct{})
+			stop := afterFunc(clock, time.Second, func() { close(fired) })
+			if tt.stop {
+				stop()
+			}
+			clock.Add(tt.advance)
+			select {
+			case <-fired:
+				if !tt.want {
+					t.Fatal("fired")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tt.want {
+					t.Fatal("not fired")
+				}
+			}
+			stop()
+		})
+	}
+}
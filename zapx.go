@@ -2,9 +2,14 @@ package zapx
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"go.opencensus.io/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
@@ -14,6 +19,9 @@ import (
 )
 
 var (
+	// RequestIDMetadataKey is the metadata key of the request ids.
+	//
+	// Deprecated: setting it is racy, use WithRequestIDKeys.
 	RequestIDMetadataKey = "x-request-id"
 
 	protomarshaler = protojson.MarshalOptions{UseProtoNames: true}
@@ -23,6 +31,38 @@ func Label(key, val string) zapcore.Field {
 	return zap.String(logKeyLabelPrefix+key, val)
 }
 
+// LogID constructs a field that routes the entry to the log id, e.g. "audit",
+// instead of the default log, so that it can be given its own retention and
+// access control. It is emitted as the "log_id" label for log routers.
+func LogID(id string) zapcore.Field {
+	return zap.String(logKeyLogID, id)
+}
+
+// NoSlack constructs a field that opts the entry, or the logger with With, out
+// of notifications, see WithSlackLevel.
+func NoSlack() zapcore.Field {
+	return zap.Bool(logKeySlackNotification, false)
+}
+
+// Metric constructs a field that carries a metric value, emitted under the
+// "metrics" object of the entry, e.g. jsonPayload.metrics.name, so that
+// log-based metrics can be defined consistently. See WithMetricRecorder to
+// record it elsewhere too.
+func Metric(name string, value float64) zapcore.Field {
+	return zap.Float64(logKeyMetricPrefix+name, value)
+}
+
+// Minimal constructs a field that skips the enrichment of the entries below
+// warn level, i.e. the resource, source location, service context and error
+// reporting context, leaving the severity, message, trace and fields, to cut
+// the size of chatty debug or access logs. Attached with With, it applies to
+// all the entries of the logger.
+func Minimal() zapcore.Field {
+	return zap.Bool(logKeyMinimal, true)
+}
+
+// Slack constructs a field that notifies the entry, to the given webhook url or
+// channel, see WithSlackToken, if any, or to the notifiers of the logger.
 func Slack(url ...string) zapcore.Field {
 	if len(url) > 0 {
 		return zap.String(logKeySlackNotification, url[0])
@@ -30,64 +70,114 @@ func Slack(url ...string) zapcore.Field {
 	return zap.Bool(logKeySlackNotification, true)
 }
 
-type jsonpbObjectMarshaler struct {
-	pb proto.Message
-}
-
-func (j *jsonpbObjectMarshaler) MarshalJSON() ([]byte, error) {
-	return protomarshaler.Marshal(j.pb)
-}
-
+// Proto constructs a field that carries val as JSON, marshaled only when the
+// entry is encoded, and once however many times it is. See WithProtoMaxBytes
+// to cap its size.
 func Proto(key string, val proto.Message) zapcore.Field {
-	return zap.Reflect(key, &jsonpbObjectMarshaler{pb: val})
+	return zap.Reflect(key, newJSONPBObjectMarshaler(val, nil, 0))
 }
 
 // Context constructs a field that carries trace span & grpc method if possible.
+// The span is looked up from OpenTelemetry, then OpenCensus, then the trace
+// propagation headers of the incoming metadata, or of the outgoing metadata
+// if there is none, e.g. in a client about to send them. The Context of an
+// entry replaces the one attached to its logger with With.
 func Context(ctx context.Context) zapcore.Field {
+	return zap.Reflect(logKeyContextInfo, contextInfoFrom(ctx))
+}
+
+func contextInfoFrom(ctx context.Context) contextInfo {
 	var info contextInfo
 	method, _ := grpc.Method(ctx)
 	info.GrpcMethod = method
-	info.RequestID = extractRequestID(ctx)
+	if id, ok := ctx.Value(requestIDContextKey{}).(string); ok {
+		info.RequestID = id
+	}
 
-	if span := trace.FromContext(ctx); span != nil || !span.SpanContext().IsSampled() {
+	// OpenTelemetry first, OpenCensus as a fallback
+	if sctx := oteltrace.SpanContextFromContext(ctx); sctx.IsValid() {
+		info.IsSampled = sctx.IsSampled()
+		info.TraceID = sctx.TraceID().String()
+		info.SpanID = sctx.SpanID().String()
+	} else if span := trace.FromContext(ctx); span != nil {
 		sctx := span.SpanContext()
 		info.IsSampled = sctx.IsSampled()
 		info.TraceID = sctx.TraceID.String()
 		info.SpanID = sctx.SpanID.String()
-	} else {
-		// try x-cloud-trace-context header
-		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			if cloudTraceHeader := md.Get("x-cloud-trace-context"); len(cloudTraceHeader) > 0 {
-				h := cloudTraceHeader[0]
-				slash := strings.Index(h, `/`)
-				if slash != -1 {
-					tid, h := h[:slash], h[slash+1:]
-					info.TraceID = tid
-					// Parse the span id field.
-					spanstr := h
-					semicolon := strings.Index(h, `;`)
-					if semicolon != -1 {
-						spanstr, h = h[:semicolon], h[semicolon+1:]
-					}
-					info.SpanID = spanstr
-					if strings.HasPrefix(h, "o=1") {
-						info.IsSampled = true
-					}
-				}
-			}
+	}
+	if span := trace.FromContext(ctx); span != nil && span.IsRecordingEvents() {
+		info.recorder = &spanRecorder{oc: span}
+	}
+	if span := oteltrace.SpanFromContext(ctx); span.IsRecording() {
+		if info.recorder == nil {
+			info.recorder = &spanRecorder{}
+		}
+		info.recorder.otel = span
+	}
+	if md, ok := incomingOrOutgoingMetadata(ctx); ok {
+		if info.TraceID == "" {
+			// try the trace propagation headers
+			traceFromMetadata(md, &info)
 		}
+		if info.RequestID == "" {
+			info.md = md
+		}
+	}
+	return info
+}
+
+// incomingOrOutgoingMetadata returns the incoming metadata of ctx, or else
+// its outgoing metadata.
+func incomingOrOutgoingMetadata(ctx context.Context) (metadata.MD, bool) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		return md, true
+	}
+	return metadata.FromOutgoingContext(ctx)
+}
+
+// DurationMS constructs a field that carries d as an integer number of
+// milliseconds, under key suffixed with "_ms" unless it already is, so that
+// log-based metrics can extract a latency distribution from it.
+func DurationMS(key string, d time.Duration) zapcore.Field {
+	return zap.Int64(msKey(key), d.Milliseconds())
+}
+
+// SinceMS is DurationMS with the time elapsed since start.
+func SinceMS(key string, start time.Time) zapcore.Field {
+	return DurationMS(key, time.Since(start))
+}
+
+func msKey(key string) string {
+	if strings.HasSuffix(key, "_ms") {
+		return key
+	}
+	return key + "_ms"
+}
+
+// If returns field if cond is true, or a no-op field otherwise.
+func If(cond bool, field zapcore.Field) zapcore.Field {
+	if !cond {
+		return zap.Skip()
 	}
+	return field
+}
 
-	return zap.Reflect(logKeyContextInfo, info)
+// NonEmpty constructs a string field, or a no-op field if value is empty.
+func NonEmpty(key, value string) zapcore.Field {
+	if value == "" {
+		return zap.Skip()
+	}
+	return zap.String(key, value)
 }
 
 func Request(req HTTPRequestEntry) zapcore.Field {
 	return zap.Object("httpRequest", req)
 }
 
-// Metadata constructs a field that carries metadata from context.
+// Metadata constructs a field that carries metadata from context, the
+// incoming metadata, or else the outgoing one.
 func Metadata(ctx context.Context) zapcore.Field {
-	md, ok := metadata.FromIncomingContext(ctx)
+	md, ok := incomingOrOutgoingMetadata(ctx)
 	if !ok {
 		return zap.Skip()
 	}
@@ -95,6 +185,17 @@ func Metadata(ctx context.Context) zapcore.Field {
 	return zap.Object("metadata", wmetadata(md))
 }
 
+// OutgoingMetadata constructs a field that carries the outgoing metadata from
+// context, i.e. the headers a grpc client sends.
+func OutgoingMetadata(ctx context.Context) zapcore.Field {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return zap.Skip()
+	}
+
+	return zap.Object("outgoingMetadata", wmetadata(md))
+}
+
 type wmetadata metadata.MD
 
 // MarshalLogObject is ObjectMarshaler implementation.
@@ -105,16 +206,77 @@ func (md wmetadata) MarshalLogObject(e zapcore.ObjectEncoder) error {
 	return nil
 }
 
+// metadataLabels maps the keys of the incoming metadata to the keys of the
+// labels they are promoted to, see WithMetadataLabels.
+type metadataLabels map[string]string
+
+// labels returns the labels of the keys of md mapped, with their first
+// value, sorted by label key.
+func (m metadataLabels) labels(md wmetadata) labels {
+	lbs := make(map[string]string, len(m))
+	for key, label := range m {
+		if vals := md[key]; len(vals) != 0 {
+			lbs[label] = vals[0]
+		}
+	}
+	return staticLabels(lbs)
+}
+
+// limitedMetadata is wmetadata with its values truncated to maxValue bytes,
+// and the whole metadata to maxTotal bytes. Zero means no limit.
+type limitedMetadata struct {
+	md       wmetadata
+	maxValue int
+	maxTotal int
+}
+
+// MarshalLogObject is ObjectMarshaler implementation.
+func (m limitedMetadata) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(m.md))
+	for key := range m.md {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	total := 0
+	for i, key := range keys {
+		vals := make([]string, len(m.md[key]))
+		for j, val := range m.md[key] {
+			vals[j] = truncateString(val, m.maxValue)
+			total += len(key) + len(vals[j])
+		}
+		if m.maxTotal > 0 && total > m.maxTotal {
+			e.AddString("…", fmt.Sprintf("(+%d keys)", len(keys)-i))
+			return nil
+		}
+		zap.Strings(key, vals).AddTo(e)
+	}
+	return nil
+}
+
+// truncateString truncates s to max bytes, at a rune boundary, marking how
+// many were cut.
+func truncateString(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return fmt.Sprintf("%s…(+%d bytes)", s[:max], len(s)-max)
+}
+
 type errorReportingContext struct {
 	reportLocation reportLocation
 	user           string
 }
 
-func (r errorReportingContext) MarshalLogObject(e zapcore.ObjectEncoder) error {
+func (r *errorReportingContext) MarshalLogObject(e zapcore.ObjectEncoder) error {
 	if r.user != "" {
 		e.AddString("user", r.user)
 	}
-	e.AddObject("reportLocation", r.reportLocation)
+	if r.reportLocation != (reportLocation{}) {
+		e.AddObject("reportLocation", &r.reportLocation)
+	}
 	return nil
 }
 
@@ -130,8 +292,10 @@ type sourceLocation struct {
 
 // MarshalLogObject is ObjectMarshaler implementation.
 func (r sourceLocation) MarshalLogObject(e zapcore.ObjectEncoder) error {
-	e.AddString("file", r.file)
-	e.AddInt("line", r.line)
+	if r.file != "" {
+		e.AddString("file", r.file)
+		e.AddInt("line", r.line)
+	}
 	e.AddString("function", r.function)
 	return nil
 }
@@ -148,14 +312,43 @@ type reportLocation struct {
 
 // MarshalLogObject is ObjectMarshaler implementation.
 func (r reportLocation) MarshalLogObject(e zapcore.ObjectEncoder) error {
-	e.AddString("filePath", r.filePath)
-	e.AddInt("lineNumber", r.lineNumber)
+	if r.filePath != "" {
+		e.AddString("filePath", r.filePath)
+		e.AddInt("lineNumber", r.lineNumber)
+	}
 	e.AddString("functionName", r.functionName)
 	return nil
 }
 
 type labels []zap.Field
 
+// merge returns the union of r and other, the labels of other taking
+// precedence.
+func (r labels) merge(other labels) labels {
+	if len(other) == 0 {
+		return r
+	}
+	if len(r) == 0 {
+		return other
+	}
+	merged := make(labels, 0, len(r)+len(other))
+	for _, f := range r {
+		if !other.has(f.Key) {
+			merged = append(merged, f)
+		}
+	}
+	return append(merged, other...)
+}
+
+func (r labels) has(key string) bool {
+	for _, f := range r {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
 func (r labels) MarshalLogObject(e zapcore.ObjectEncoder) error {
 	for _, f := range r {
 		f.AddTo(e)
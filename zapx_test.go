@@ -0,0 +1,41 @@
+package zapx
+
+import (
+	"context"
+	"testing"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestContextInfoFrom(t *testing.T) {
+	const (
+		spanTrace   = "0102030405060708090a0b0c0d0e0f10"
+		headerTrace = "ffeeddccbbaa99887766554433221100"
+	)
+	tid, _ := oteltrace.TraceIDFromHex(spanTrace)
+	sid, _ := oteltrace.SpanIDFromHex("0102030405060708")
+	header := metadata.Pairs("traceparent", "00-"+headerTrace+"-0011223344556677-01")
+	tests := []struct {
+		name        string
+		flags       oteltrace.TraceFlags
+		span        bool
+		wantTrace   string
+		wantSampled bool
+	}{
+		{"sampled span", oteltrace.FlagsSampled, true, spanTrace, true},
+		{"unsampled span kept over the headers", 0, true, spanTrace, false},
+		{"headers without span", 0, false, headerTrace, true},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), header)
+		if tt.span {
+			sctx := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: tt.flags})
+			ctx = oteltrace.ContextWithSpanContext(ctx, sctx)
+		}
+		info := contextInfoFrom(ctx)
+		if info.TraceID != tt.wantTrace || info.IsSampled != tt.wantSampled {
+			t.Errorf("%s: trace %s sampled %v, want %s sampled %v", tt.name, info.TraceID, info.IsSampled, tt.wantTrace, tt.wantSampled)
+		}
+	}
+}
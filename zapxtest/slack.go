@@ -0,0 +1,105 @@
+package zapxtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// SlackRecorder is a fake Slack incoming webhook, recording the messages
+// posted to its URL, e.g. passed to zapx.WithSlackURL.
+type SlackRecorder struct {
+	// URL is the url of the webhook.
+	URL string
+
+	server *httptest.Server
+
+	mu         sync.Mutex
+	messages   []slack.WebhookMessage
+	requests   int
+	limited    int
+	retryAfter time.Duration
+	failed     int
+	status     int
+}
+
+// NewSlackRecorder starts a fake webhook, to be closed by Close.
+func NewSlackRecorder() *SlackRecorder {
+	r := &SlackRecorder{}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	r.URL = r.server.URL + "/services/T00000000/B00000000/XXXXXXXXXXXXXXXXXXXXXXXX"
+	return r
+}
+
+func (r *SlackRecorder) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if req.Method != http.MethodPost {
+		http.Error(w, "invalid_request", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.limited > 0 {
+		r.limited--
+		w.Header().Set("Retry-After", strconv.Itoa(int(r.retryAfter/time.Second)))
+		http.Error(w, "rate_limited", http.StatusTooManyRequests)
+		return
+	}
+	if r.failed > 0 {
+		r.failed--
+		http.Error(w, http.StatusText(r.status), r.status)
+		return
+	}
+	var msg slack.WebhookMessage
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+		return
+	}
+	if msg.Text == "" && len(msg.Attachments) == 0 && (msg.Blocks == nil || len(msg.Blocks.BlockSet) == 0) {
+		http.Error(w, "no_text", http.StatusBadRequest)
+		return
+	}
+	r.messages = append(r.messages, msg)
+	w.Write([]byte("ok"))
+}
+
+// RateLimit answers the next n posts with 429 Too Many Requests, asking to
+// retry after retryAfter, in whole seconds as Slack does.
+func (r *SlackRecorder) RateLimit(n int, retryAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limited, r.retryAfter = n, retryAfter
+}
+
+// Fail answers the next n posts, after the rate limited ones, with status,
+// e.g. http.StatusInternalServerError.
+func (r *SlackRecorder) Fail(n, status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed, r.status = n, status
+}
+
+// Messages returns the messages accepted so far.
+func (r *SlackRecorder) Messages() []slack.WebhookMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]slack.WebhookMessage(nil), r.messages...)
+}
+
+// Requests returns the number of posts received so far, the rejected ones
+// included, e.g. to count the retries.
+func (r *SlackRecorder) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+// Close shuts the webhook down.
+func (r *SlackRecorder) Close() {
+	r.server.Close()
+}
@@ -0,0 +1,138 @@
+// Package zapxtest records what a zapx logger would send to Cloud Logging and
+// to the notifiers, for the tests of the applications.
+package zapxtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/lixin9311/zapx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is an entry as written to Cloud Logging.
+type Entry struct {
+	Severity string
+	Message  string
+	// Fields are all the keys of the JSON payload, e.g. serviceContext,
+	// logging.googleapis.com/labels and logging.googleapis.com/trace.
+	Fields map[string]interface{}
+}
+
+// Labels returns the labels of the entry.
+func (e Entry) Labels() map[string]string {
+	lbs, _ := e.Fields["logging.googleapis.com/labels"].(map[string]interface{})
+	out := make(map[string]string, len(lbs))
+	for k, v := range lbs {
+		if s, ok := v.(string); ok {
+			out[k] = s
+		}
+	}
+	return out
+}
+
+// Trace returns the trace of the entry, if any.
+func (e Entry) Trace() string {
+	trace, _ := e.Fields["logging.googleapis.com/trace"].(string)
+	return trace
+}
+
+// Notification is an entry as delivered to the notifiers.
+type Notification struct {
+	Entry zapcore.Entry
+	// Fields are the fields of the notification, encoded by a
+	// zapcore.MapObjectEncoder.
+	Fields map[string]interface{}
+}
+
+// Recorder holds the entries and the notifications of a logger created by
+// New.
+type Recorder struct {
+	mu            sync.Mutex
+	partial       []byte
+	entries       []Entry
+	notifications []Notification
+}
+
+// New returns a logger writing at level, with opts, and the recorder of its
+// entries and notifications. The notifications are delivered asynchronously:
+// call Sync on the logger before looking them up.
+func New(level zapcore.Level, opts ...zapx.Option) (*zap.Logger, *Recorder) {
+	r := &Recorder{}
+	opts = append(opts, zapx.WithOutput(zapcore.AddSync(r)), zapx.WithNotifier(zapx.NotifierFunc(r.notify)))
+	return zapx.Zap(level, opts...), r
+}
+
+// Write decodes the entries encoded by the core, one per line.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i == -1 {
+			break
+		}
+		line := r.partial[:i]
+		r.partial = r.partial[i+1:]
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return 0, err
+		}
+		e := Entry{Fields: fields}
+		e.Severity, _ = fields["severity"].(string)
+		e.Message, _ = fields["message"].(string)
+		r.entries = append(r.entries, e)
+	}
+	return len(p), nil
+}
+
+func (r *Recorder) notify(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, Notification{Entry: ent, Fields: enc.Fields})
+	return nil
+}
+
+// Entries returns the entries written so far.
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Notifications returns the notifications delivered so far.
+func (r *Recorder) Notifications() []Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Notification(nil), r.notifications...)
+}
+
+// FilterMessage returns the entries written with the message msg.
+func (r *Recorder) FilterMessage(msg string) []Entry {
+	var out []Entry
+	for _, e := range r.Entries() {
+		if e.Message == msg {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Reset forgets the entries and the notifications recorded.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+	r.notifications = nil
+}